@@ -2,6 +2,8 @@ package abstractSyntaxTree
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 
 	"github.com/Favot/monkey-interpreter/token"
 )
@@ -9,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+	End() token.Position
 }
 
 type Statement interface {
@@ -23,6 +27,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*CommentGroup
 }
 
 func (program *Program) TokenLiteral() string {
@@ -43,7 +48,22 @@ func (program *Program) String() string {
 	return out.String()
 }
 
+func (program *Program) Pos() token.Position {
+	if len(program.Statements) > 0 {
+		return program.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (program *Program) End() token.Position {
+	if len(program.Statements) > 0 {
+		return program.Statements[len(program.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 type LetStatement struct {
+	Doc   *CommentGroup
 	Token token.Token
 	Name  *Identifier
 	Value Expression
@@ -51,6 +71,16 @@ type LetStatement struct {
 
 func (letStatement *LetStatement) statementNode()       {}
 func (letStatement *LetStatement) TokenLiteral() string { return letStatement.Token.Literal }
+func (letStatement *LetStatement) Pos() token.Position  { return letStatement.Token.Span.Start }
+func (letStatement *LetStatement) End() token.Position {
+	if letStatement.Value != nil {
+		return letStatement.Value.End()
+	}
+	if letStatement.Name != nil {
+		return letStatement.Name.End()
+	}
+	return letStatement.Token.Span.End
+}
 func (letStatement *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -58,7 +88,7 @@ func (letStatement *LetStatement) String() string {
 	out.WriteString(letStatement.Name.String())
 	out.WriteString(" = ")
 
-	if letStatement != nil {
+	if letStatement.Value != nil {
 		out.WriteString(letStatement.Value.String())
 	}
 
@@ -74,6 +104,8 @@ type Identifier struct {
 
 func (identifier *Identifier) expressionNode()      {}
 func (identifier *Identifier) TokenLiteral() string { return identifier.Token.Literal }
+func (identifier *Identifier) Pos() token.Position  { return identifier.Token.Span.Start }
+func (identifier *Identifier) End() token.Position  { return identifier.Token.Span.End }
 func (identifer *Identifier) String() string        { return identifer.Value }
 
 type ReturnStatement struct {
@@ -85,6 +117,13 @@ func (returnStatement *ReturnStatement) statementNode() {}
 func (returnStatement *ReturnStatement) TokenLiteral() string {
 	return returnStatement.Token.Literal
 }
+func (returnStatement *ReturnStatement) Pos() token.Position { return returnStatement.Token.Span.Start }
+func (returnStatement *ReturnStatement) End() token.Position {
+	if returnStatement.ReturnValue != nil {
+		return returnStatement.ReturnValue.End()
+	}
+	return returnStatement.Token.Span.End
+}
 func (returnStatement *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -108,6 +147,18 @@ func (expressionStatement *ExpressionStatement) statementNode() {}
 func (expressionStatement *ExpressionStatement) TokenLiteral() string {
 	return expressionStatement.Token.Literal
 }
+func (expressionStatement *ExpressionStatement) Pos() token.Position {
+	if expressionStatement.Expression != nil {
+		return expressionStatement.Expression.Pos()
+	}
+	return expressionStatement.Token.Span.Start
+}
+func (expressionStatement *ExpressionStatement) End() token.Position {
+	if expressionStatement.Expression != nil {
+		return expressionStatement.Expression.End()
+	}
+	return expressionStatement.Token.Span.End
+}
 func (expressionStatement *ExpressionStatement) String() string {
 	if expressionStatement.Expression != nil {
 		return expressionStatement.Expression.String()
@@ -123,6 +174,61 @@ type IntegerLiteral struct {
 func (integerLiteral *IntegerLiteral) expressionNode()      {}
 func (integerLiteral *IntegerLiteral) TokenLiteral() string { return integerLiteral.Token.Literal }
 func (integerLiteral *IntegerLiteral) String() string       { return integerLiteral.Token.Literal }
+func (integerLiteral *IntegerLiteral) Pos() token.Position  { return integerLiteral.Token.Span.Start }
+func (integerLiteral *IntegerLiteral) End() token.Position  { return integerLiteral.Token.Span.End }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (floatLiteral *FloatLiteral) expressionNode()      {}
+func (floatLiteral *FloatLiteral) TokenLiteral() string { return floatLiteral.Token.Literal }
+func (floatLiteral *FloatLiteral) String() string       { return floatLiteral.Token.Literal }
+func (floatLiteral *FloatLiteral) Pos() token.Position  { return floatLiteral.Token.Span.Start }
+func (floatLiteral *FloatLiteral) End() token.Position  { return floatLiteral.Token.Span.End }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (stringLiteral *StringLiteral) expressionNode()      {}
+func (stringLiteral *StringLiteral) TokenLiteral() string { return stringLiteral.Token.Literal }
+func (stringLiteral *StringLiteral) String() string       { return Quote(stringLiteral.Value) }
+func (stringLiteral *StringLiteral) Pos() token.Position  { return stringLiteral.Token.Span.Start }
+func (stringLiteral *StringLiteral) End() token.Position  { return stringLiteral.Token.Span.End }
+
+// Quote returns value as a Monkey string literal, escaping only what the
+// lexer would otherwise misread.
+func Quote(value string) string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+	for _, char := range value {
+		switch char {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if char < ' ' || char == 0x7f {
+				fmt.Fprintf(&out, "\\u{%x}", char)
+			} else {
+				out.WriteRune(char)
+			}
+		}
+	}
+	out.WriteByte('"')
+
+	return out.String()
+}
 
 type PrefixEpression struct {
 	Token    token.Token
@@ -132,6 +238,15 @@ type PrefixEpression struct {
 
 func (prefixExpression *PrefixEpression) expressionNode()      {}
 func (prefixExpression *PrefixEpression) TokenLiteral() string { return prefixExpression.Token.Literal }
+func (prefixExpression *PrefixEpression) Pos() token.Position {
+	return prefixExpression.Token.Span.Start
+}
+func (prefixExpression *PrefixEpression) End() token.Position {
+	if prefixExpression.Rigth != nil {
+		return prefixExpression.Rigth.End()
+	}
+	return prefixExpression.Token.Span.End
+}
 func (prefixExpression *PrefixEpression) String() string {
 	var out bytes.Buffer
 
@@ -152,6 +267,18 @@ type InfixExpression struct {
 
 func (infixExpression *InfixExpression) expressionNode()      {}
 func (infixExpression *InfixExpression) TokenLiteral() string { return infixExpression.Token.Literal }
+func (infixExpression *InfixExpression) Pos() token.Position {
+	if infixExpression.Left != nil {
+		return infixExpression.Left.Pos()
+	}
+	return infixExpression.Token.Span.Start
+}
+func (infixExpression *InfixExpression) End() token.Position {
+	if infixExpression.Right != nil {
+		return infixExpression.Right.End()
+	}
+	return infixExpression.Token.Span.End
+}
 func (infixExpression *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -163,3 +290,279 @@ func (infixExpression *InfixExpression) String() string {
 
 	return out.String()
 }
+
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (boolean *Boolean) expressionNode()      {}
+func (boolean *Boolean) TokenLiteral() string { return boolean.Token.Literal }
+func (boolean *Boolean) String() string       { return boolean.Token.Literal }
+func (boolean *Boolean) Pos() token.Position  { return boolean.Token.Span.Start }
+func (boolean *Boolean) End() token.Position  { return boolean.Token.Span.End }
+
+type BlockStatement struct {
+	Token      token.Token
+	Statements []Statement
+	RightBrace token.Token
+}
+
+func (blockStatement *BlockStatement) statementNode()       {}
+func (blockStatement *BlockStatement) TokenLiteral() string { return blockStatement.Token.Literal }
+func (blockStatement *BlockStatement) Pos() token.Position  { return blockStatement.Token.Span.Start }
+func (blockStatement *BlockStatement) End() token.Position {
+	if blockStatement.RightBrace.Span.End.IsValid() {
+		return blockStatement.RightBrace.Span.End
+	}
+	if len(blockStatement.Statements) > 0 {
+		return blockStatement.Statements[len(blockStatement.Statements)-1].End()
+	}
+	return blockStatement.Token.Span.End
+}
+func (blockStatement *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	for _, statement := range blockStatement.Statements {
+		out.WriteString(statement.String())
+	}
+
+	return out.String()
+}
+
+type IfExpression struct {
+	Token       token.Token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ifExpression *IfExpression) expressionNode()      {}
+func (ifExpression *IfExpression) TokenLiteral() string { return ifExpression.Token.Literal }
+func (ifExpression *IfExpression) Pos() token.Position  { return ifExpression.Token.Span.Start }
+func (ifExpression *IfExpression) End() token.Position {
+	if ifExpression.Alternative != nil {
+		return ifExpression.Alternative.End()
+	}
+	if ifExpression.Consequence != nil {
+		return ifExpression.Consequence.End()
+	}
+	return ifExpression.Token.Span.End
+}
+func (ifExpression *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if")
+	out.WriteString(ifExpression.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ifExpression.Consequence.String())
+
+	if ifExpression.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(ifExpression.Alternative.String())
+	}
+
+	return out.String()
+}
+
+type FunctionLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (functionLiteral *FunctionLiteral) expressionNode()      {}
+func (functionLiteral *FunctionLiteral) TokenLiteral() string { return functionLiteral.Token.Literal }
+func (functionLiteral *FunctionLiteral) Pos() token.Position  { return functionLiteral.Token.Span.Start }
+func (functionLiteral *FunctionLiteral) End() token.Position {
+	if functionLiteral.Body != nil {
+		return functionLiteral.Body.End()
+	}
+	return functionLiteral.Token.Span.End
+}
+func (functionLiteral *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	parameters := []string{}
+	for _, parameter := range functionLiteral.Parameters {
+		parameters = append(parameters, parameter.String())
+	}
+
+	out.WriteString(functionLiteral.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(parameters, ", "))
+	out.WriteString(") ")
+	out.WriteString(functionLiteral.Body.String())
+
+	return out.String()
+}
+
+type CallExpression struct {
+	Token            token.Token
+	Function         Expression
+	Arguments        []Expression
+	RightParenthesis token.Token
+}
+
+func (callExpression *CallExpression) expressionNode()      {}
+func (callExpression *CallExpression) TokenLiteral() string { return callExpression.Token.Literal }
+func (callExpression *CallExpression) Pos() token.Position  { return callExpression.Function.Pos() }
+func (callExpression *CallExpression) End() token.Position {
+	if callExpression.RightParenthesis.Span.End.IsValid() {
+		return callExpression.RightParenthesis.Span.End
+	}
+	return callExpression.Token.Span.End
+}
+func (callExpression *CallExpression) String() string {
+	var out bytes.Buffer
+
+	arguments := []string{}
+	for _, argument := range callExpression.Arguments {
+		arguments = append(arguments, argument.String())
+	}
+
+	out.WriteString(callExpression.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(arguments, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	Token        token.Token
+	Elements     []Expression
+	RightBracket token.Token
+}
+
+func (arrayLiteral *ArrayLiteral) expressionNode()      {}
+func (arrayLiteral *ArrayLiteral) TokenLiteral() string { return arrayLiteral.Token.Literal }
+func (arrayLiteral *ArrayLiteral) Pos() token.Position  { return arrayLiteral.Token.Span.Start }
+func (arrayLiteral *ArrayLiteral) End() token.Position {
+	if arrayLiteral.RightBracket.Span.End.IsValid() {
+		return arrayLiteral.RightBracket.Span.End
+	}
+	if len(arrayLiteral.Elements) > 0 {
+		return arrayLiteral.Elements[len(arrayLiteral.Elements)-1].End()
+	}
+	return arrayLiteral.Token.Span.End
+}
+func (arrayLiteral *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, element := range arrayLiteral.Elements {
+		elements = append(elements, element.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token        token.Token
+	Left         Expression
+	Index        Expression
+	RightBracket token.Token
+}
+
+func (indexExpression *IndexExpression) expressionNode()      {}
+func (indexExpression *IndexExpression) TokenLiteral() string { return indexExpression.Token.Literal }
+func (indexExpression *IndexExpression) Pos() token.Position  { return indexExpression.Left.Pos() }
+func (indexExpression *IndexExpression) End() token.Position {
+	if indexExpression.RightBracket.Span.End.IsValid() {
+		return indexExpression.RightBracket.Span.End
+	}
+	if indexExpression.Index != nil {
+		return indexExpression.Index.End()
+	}
+	return indexExpression.Token.Span.End
+}
+func (indexExpression *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(indexExpression.Left.String())
+	out.WriteString("[")
+	out.WriteString(indexExpression.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// HashLiteral keeps its pairs in source order so that printing a hash gives
+// back the layout it was written with.
+type HashLiteral struct {
+	Token      token.Token
+	Pairs      []HashPair
+	RightBrace token.Token
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hashLiteral *HashLiteral) expressionNode()      {}
+func (hashLiteral *HashLiteral) TokenLiteral() string { return hashLiteral.Token.Literal }
+func (hashLiteral *HashLiteral) Pos() token.Position  { return hashLiteral.Token.Span.Start }
+func (hashLiteral *HashLiteral) End() token.Position {
+	if hashLiteral.RightBrace.Span.End.IsValid() {
+		return hashLiteral.RightBrace.Span.End
+	}
+	if len(hashLiteral.Pairs) > 0 {
+		last := hashLiteral.Pairs[len(hashLiteral.Pairs)-1]
+		if last.Value != nil {
+			return last.Value.End()
+		}
+		return last.Key.End()
+	}
+	return hashLiteral.Token.Span.End
+}
+func (hashLiteral *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hashLiteral.Pairs {
+		value := ""
+		if pair.Value != nil {
+			value = pair.Value.String()
+		}
+		pairs = append(pairs, pair.Key.String()+": "+value)
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// BadExpression and BadStatement stand in for source the parser could not
+// make sense of, so that the rest of the tree survives a syntax error.
+type BadExpression struct {
+	Token token.Token
+	From  token.Position
+	To    token.Position
+}
+
+func (badExpression *BadExpression) expressionNode()      {}
+func (badExpression *BadExpression) TokenLiteral() string { return badExpression.Token.Literal }
+func (badExpression *BadExpression) String() string       { return "<bad expression>" }
+func (badExpression *BadExpression) Pos() token.Position  { return badExpression.From }
+func (badExpression *BadExpression) End() token.Position  { return badExpression.To }
+
+type BadStatement struct {
+	Token token.Token
+	From  token.Position
+	To    token.Position
+}
+
+func (badStatement *BadStatement) statementNode()       {}
+func (badStatement *BadStatement) TokenLiteral() string { return badStatement.Token.Literal }
+func (badStatement *BadStatement) String() string       { return "<bad statement>" }
+func (badStatement *BadStatement) Pos() token.Position  { return badStatement.From }
+func (badStatement *BadStatement) End() token.Position  { return badStatement.To }
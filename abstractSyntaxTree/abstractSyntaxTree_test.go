@@ -1,6 +1,8 @@
 package abstractSyntaxTree
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Favot/monkey-interpreter/token"
@@ -28,3 +30,484 @@ func TestString(t *testing.T) {
 	}
 
 }
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", `"plain"`},
+		{"say \"hi\"\n", `"say \"hi\"\n"`},
+		{"back\\slash\ttab", `"back\\slash\ttab"`},
+		{"bell\a", `"bell\u{7}"`},
+		{"café 😀", `"café 😀"`},
+	}
+
+	for _, tt := range tests {
+		if actual := Quote(tt.input); actual != tt.expected {
+			t.Errorf("Quote(%q) wrong. expected=%s, got=%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func identifier(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value}
+}
+
+func infix(left Expression, operator string, right Expression) *InfixExpression {
+	return &InfixExpression{Token: token.Token{Literal: operator}, Left: left, Operator: operator, Right: right}
+}
+
+func let(name string, value Expression) *LetStatement {
+	return &LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: identifier(name), Value: value}
+}
+
+func statement(expression Expression) *ExpressionStatement {
+	return &ExpressionStatement{Expression: expression}
+}
+
+// describe names a node for the traversal tests.
+func describe(node Node) string {
+	if node == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%T(%s)", node, node.String())[1:]
+}
+
+type recorder struct{ visits *[]string }
+
+func (recorder recorder) Visit(node Node) Visitor {
+	*recorder.visits = append(*recorder.visits, describe(node))
+	return recorder
+}
+
+func TestWalk(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		let("x", infix(integer(1), "+", identifier("y"))),
+		&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}},
+	}}
+
+	var visits []string
+	Walk(recorder{&visits}, program)
+
+	expected := []string{
+		"abstractSyntaxTree.Program(let x = (1 + y);return ;)",
+		"abstractSyntaxTree.LetStatement(let x = (1 + y);)",
+		"abstractSyntaxTree.Identifier(x)", "nil",
+		"abstractSyntaxTree.InfixExpression((1 + y))",
+		"abstractSyntaxTree.IntegerLiteral(1)", "nil",
+		"abstractSyntaxTree.Identifier(y)", "nil",
+		"nil",
+		"nil",
+		"abstractSyntaxTree.ReturnStatement(return ;)", "nil",
+		"nil",
+	}
+	if strings.Join(visits, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("visits wrong.\nexpected=%q\ngot=%q", expected, visits)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		statement(infix(identifier("a"), "*", infix(identifier("b"), "+", identifier("c")))),
+	}}
+
+	var names []string
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			names = append(names, node.Value)
+		case *InfixExpression:
+			return node.Operator != "+"
+		}
+		return true
+	})
+
+	if strings.Join(names, " ") != "a" {
+		t.Errorf("inspected identifiers wrong. got=%q", names)
+	}
+}
+
+func TestApply(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		let("x", infix(integer(1), "+", infix(integer(2), "*", integer(3)))),
+		statement(identifier("debug")),
+		statement(&CallExpression{Function: identifier("f"), Arguments: []Expression{identifier("x")}}),
+	}}
+
+	fold := func(cursor *Cursor) bool {
+		if expression, ok := cursor.Node().(*InfixExpression); ok {
+			left, leftOk := expression.Left.(*IntegerLiteral)
+			right, rightOk := expression.Right.(*IntegerLiteral)
+			if leftOk && rightOk && expression.Operator == "+" {
+				cursor.Replace(integer(left.Value + right.Value))
+			} else if leftOk && rightOk && expression.Operator == "*" {
+				cursor.Replace(integer(left.Value * right.Value))
+			}
+		}
+		return true
+	}
+
+	edit := func(cursor *Cursor) bool {
+		switch node := cursor.Node().(type) {
+		case *ExpressionStatement:
+			if identifier, ok := node.Expression.(*Identifier); ok && identifier.Value == "debug" {
+				cursor.Delete()
+			}
+		case *LetStatement:
+			cursor.InsertBefore(let("w", integer(0)))
+			cursor.InsertAfter(statement(identifier("x")))
+		case *Identifier:
+			if node.Value == "x" && cursor.Name() == "Arguments" {
+				cursor.InsertAfter(identifier("y"))
+			}
+		}
+		return true
+	}
+
+	result := Apply(program, edit, fold)
+
+	if result != program {
+		t.Fatalf("Apply returned another root. got=%T", result)
+	}
+	if program.String() != "let w = 0;let x = 7;xf(x, y)" {
+		t.Errorf("program wrong. got=%q", program.String())
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	hash := &HashLiteral{Pairs: []HashPair{{Key: identifier("k"), Value: integer(1)}}}
+	call := &CallExpression{Function: identifier("f"), Arguments: []Expression{integer(1), hash}}
+	program := &Program{Statements: []Statement{statement(call)}}
+
+	var visits []string
+	Apply(program, func(cursor *Cursor) bool {
+		parent := "nil"
+		if cursor.Parent() != nil {
+			parent = strings.TrimPrefix(fmt.Sprintf("%T", cursor.Parent()), "*abstractSyntaxTree.")
+		}
+		visits = append(visits, fmt.Sprintf("%s.%s[%d]=%s", parent, cursor.Name(), cursor.Index(), cursor.Node().String()))
+		return true
+	}, nil)
+
+	expected := []string{
+		"nil.Node[-1]=f(1, {k: 1})",
+		"Program.Statements[0]=f(1, {k: 1})",
+		"ExpressionStatement.Expression[-1]=f(1, {k: 1})",
+		"CallExpression.Function[-1]=f",
+		"CallExpression.Arguments[0]=1",
+		"CallExpression.Arguments[1]={k: 1}",
+		"HashLiteral.Key[0]=k",
+		"HashLiteral.Value[0]=1",
+	}
+	if strings.Join(visits, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("cursors wrong.\nexpected=%q\ngot=%q", expected, visits)
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	result := Apply(infix(integer(1), "+", integer(2)), nil, func(cursor *Cursor) bool {
+		if cursor.Parent() == nil {
+			cursor.Replace(integer(3))
+		}
+		return true
+	})
+
+	if result.String() != "3" {
+		t.Errorf("root not replaced. got=%q", result.String())
+	}
+}
+
+func TestApplyStop(t *testing.T) {
+	program := &Program{Statements: []Statement{statement(identifier("a")), statement(identifier("b"))}}
+
+	var names []string
+	Apply(program, nil, func(cursor *Cursor) bool {
+		if identifier, ok := cursor.Node().(*Identifier); ok {
+			names = append(names, identifier.Value)
+			return false
+		}
+		return true
+	})
+
+	if strings.Join(names, " ") != "a" {
+		t.Errorf("traversal did not stop. got=%q", names)
+	}
+}
+
+func TestApplyPanics(t *testing.T) {
+	tests := []struct {
+		edit     ApplyFunc
+		expected string
+	}{
+		{
+			func(cursor *Cursor) bool {
+				if _, ok := cursor.Node().(*InfixExpression); ok {
+					cursor.Delete()
+				}
+				return true
+			},
+			"Delete node not contained in a slice",
+		},
+		{
+			func(cursor *Cursor) bool {
+				if _, ok := cursor.Node().(*InfixExpression); ok {
+					cursor.Replace(statement(integer(1)))
+				}
+				return true
+			},
+			"cannot use *abstractSyntaxTree.ExpressionStatement as abstractSyntaxTree.Expression",
+		},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != tt.expected {
+					t.Errorf("panic wrong. expected=%q, got=%v", tt.expected, r)
+				}
+			}()
+
+			Apply(&Program{Statements: []Statement{statement(infix(integer(1), "+", integer(2)))}}, tt.edit, nil)
+		}()
+	}
+}
+
+func sampleProgram() *Program {
+	hash := &HashLiteral{Pairs: []HashPair{{Key: &StringLiteral{Value: "k"}, Value: &FloatLiteral{Token: token.Token{Literal: "2.5"}, Value: 2.5}}}}
+	body := &BlockStatement{Statements: []Statement{
+		&ReturnStatement{ReturnValue: infix(identifier("x"), "*", &PrefixEpression{Operator: "-", Rigth: integer(2)})},
+	}}
+
+	return &Program{Statements: []Statement{
+		&LetStatement{
+			Doc:   &CommentGroup{List: []token.Trivia{{Kind: token.LINE_COMMENT, Text: "// Double."}}},
+			Name:  identifier("double"),
+			Value: &FunctionLiteral{Parameters: []*Identifier{identifier("x")}, Body: body},
+		},
+		statement(&IfExpression{
+			Condition:   &Boolean{Value: true},
+			Consequence: &BlockStatement{Statements: []Statement{statement(&ArrayLiteral{Elements: []Expression{hash}})}},
+		}),
+		statement(&IndexExpression{
+			Left:  &CallExpression{Function: identifier("double"), Arguments: []Expression{integer(1)}},
+			Index: integer(0),
+		}),
+		&ReturnStatement{},
+	}}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	data, err := EncodeJSON(let("x", &PrefixEpression{Operator: "!", Rigth: &Boolean{Value: true}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+  "kind": "LetStatement",
+  "name": {
+    "kind": "Identifier",
+    "name": "x"
+  },
+  "value": {
+    "kind": "PrefixExpression",
+    "operator": "!",
+    "operand": {
+      "kind": "Boolean",
+      "value": true
+    }
+  }
+}`
+	if string(data) != expected {
+		t.Errorf("EncodeJSON wrong.\nexpected=%s\ngot=%s", expected, data)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	program := sampleProgram()
+	program.Statements = append(program.Statements, &LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let", Span: token.Span{
+			Start: token.Position{Line: 3, Column: 1, Offset: 20},
+			End:   token.Position{Line: 3, Column: 4, Offset: 23},
+		}},
+		Name: identifier("y"),
+		Value: &ArrayLiteral{
+			Token:        token.Token{Span: token.Span{Start: token.Position{Line: 3, Column: 9, Offset: 28}}},
+			RightBracket: token.Token{Span: token.Span{End: token.Position{Line: 4, Column: 2, Offset: 31}}},
+		},
+	})
+
+	data, err := EncodeJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeJSON failed: %s", err)
+	}
+
+	decoded, ok := node.(*Program)
+	if !ok {
+		t.Fatalf("node is not *Program. got=%T", node)
+	}
+	expected := `let double = fn(x) return (x * (-2));;iftrue [{"k": 2.5}](double(1)[0])return ;let y = [];`
+	if decoded.String() != expected {
+		t.Errorf("program wrong.\nexpected=%q\ngot=%q", expected, decoded.String())
+	}
+
+	again, err := EncodeJSON(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("encoding the decoded program differs.\nexpected=%s\ngot=%s", data, again)
+	}
+
+	array := decoded.Statements[len(decoded.Statements)-1].(*LetStatement).Value
+	if array.Pos().Line != 3 || array.End().Line != 4 || array.End().Column != 2 {
+		t.Errorf("span wrong. got=%v-%v", array.Pos(), array.End())
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	node, err := DecodeJSON([]byte(`{"kind": "Program", "statements": [
+		{"kind": "LetStatement", "name": {"kind": "Identifier", "name": "n"},
+		 "value": {"kind": "IntegerLiteral", "value": 31, "literal": "0x1f"}},
+		{"kind": "ExpressionStatement", "expression": {"kind": "CallExpression",
+		 "function": {"kind": "Identifier", "name": "puts"},
+		 "arguments": [{"kind": "InfixExpression", "operator": "+",
+		  "left": {"kind": "Identifier", "name": "n"}, "right": {"kind": "IntegerLiteral", "value": 1}}]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.String() != "let n = 0x1f;puts((n + 1))" {
+		t.Errorf("program wrong. got=%q", node.String())
+	}
+}
+
+func TestDecodeJSONLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind": "IntegerLiteral", "value": 15, "literal": "0o17"}`, "0o17"},
+		{`{"kind": "IntegerLiteral", "value": -2, "literal": "-2"}`, "-2"},
+		{`{"kind": "IntegerLiteral", "value": -9223372036854775808}`, "-9223372036854775808"},
+		{`{"kind": "FloatLiteral", "value": 1}`, "1.0"},
+		{`{"kind": "FloatLiteral", "value": -3}`, "-3.0"},
+		{`{"kind": "FloatLiteral", "value": 1e21}`, "1e+21"},
+		{`{"kind": "FloatLiteral", "value": 1000, "literal": "1_000.0"}`, "1_000.0"},
+		{`{"kind": "Identifier", "name": "caf\u00e9"}`, "café"},
+	}
+
+	for _, tt := range tests {
+		node, err := DecodeJSON([]byte(tt.input))
+		if err != nil {
+			t.Errorf("DecodeJSON(%s) failed: %v", tt.input, err)
+			continue
+		}
+		if node.String() != tt.expected {
+			t.Errorf("DecodeJSON(%s) wrong. expected=%q, got=%q", tt.input, tt.expected, node.String())
+		}
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[]`, "document: expected an object"},
+		{`null`, "expected a node, got null"},
+		{`{"kind": "Loop"}`, `document: unknown node kind "Loop"`},
+		{`{"name": "x"}`, "kind: missing"},
+		{
+			`{"kind": "Program", "statements": [{"kind": "LetStatement", "name": {"kind": "Identifier", "name": "x"}}]}`,
+			"statements[0].value: missing",
+		},
+		{
+			`{"kind": "Program", "statements": [{"kind": "Identifier", "name": "x"}]}`,
+			"statements[0]: Identifier cannot go here",
+		},
+		{
+			`{"kind": "ArrayLiteral", "elements": [{"kind": "IntegerLiteral", "value": "1"}]}`,
+			"elements[0].value: json: cannot unmarshal string into Go value of type int64",
+		},
+		{
+			`{"kind": "CommentGroup", "comments": [{"kind": "hash", "text": "# x"}]}`,
+			`comments[0].kind: unknown comment kind "hash"`,
+		},
+		{`{"kind": "Identifier", "name": "x y"}`, `name: "x y" is not an identifier`},
+		{`{"kind": "Identifier", "name": "let"}`, `name: "let" is not an identifier`},
+		{`{"kind": "Identifier", "name": ""}`, `name: "" is not an identifier`},
+		{
+			`{"kind": "PrefixExpression", "operator": "+", "operand": {"kind": "IntegerLiteral", "value": 1}}`,
+			`operator: unknown prefix operator "+"`,
+		},
+		{
+			`{"kind": "InfixExpression", "operator": "^",
+			  "left": {"kind": "IntegerLiteral", "value": 1}, "right": {"kind": "IntegerLiteral", "value": 2}}`,
+			`operator: unknown infix operator "^"`,
+		},
+		{`{"kind": "IntegerLiteral", "value": 5, "literal": "7"}`, `literal: "7" does not match value 5`},
+		{`{"kind": "IntegerLiteral", "value": 755, "literal": "0755"}`, `literal: "0755" does not match value 755`},
+		{`{"kind": "IntegerLiteral", "value": 1, "literal": "1.0"}`, `literal: "1.0" does not match value 1`},
+		{`{"kind": "FloatLiteral", "value": 1, "literal": "1"}`, `literal: "1" does not match value 1`},
+		{`{"kind": "FloatLiteral", "value": 0.5, "literal": "0.25"}`, `literal: "0.25" does not match value 0.5`},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("DecodeJSON(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestSExpression(t *testing.T) {
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{infix(integer(1), "+", identifier("x")), "(InfixExpression + (IntegerLiteral 1) (Identifier x))"},
+		{&ReturnStatement{}, "(ReturnStatement)"},
+		{
+			sampleProgram(),
+			`(Program
+  (LetStatement
+    (Identifier double)
+    (FunctionLiteral
+      (Identifier x)
+      (BlockStatement
+        (ReturnStatement
+          (InfixExpression *
+            (Identifier x)
+            (PrefixExpression - (IntegerLiteral 2)))))))
+  (ExpressionStatement
+    (IfExpression
+      (Boolean true)
+      (BlockStatement
+        (ExpressionStatement
+          (ArrayLiteral
+            (HashLiteral (HashPair (StringLiteral "k") (FloatLiteral 2.5))))))))
+  (ExpressionStatement
+    (IndexExpression
+      (CallExpression (Identifier double) (IntegerLiteral 1))
+      (IntegerLiteral 0)))
+  (ReturnStatement))`,
+		},
+	}
+
+	for _, tt := range tests {
+		if actual := SExpression(tt.node); actual != tt.expected {
+			t.Errorf("SExpression wrong.\nexpected=%s\ngot=%s", tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package abstractSyntaxTree
+
+import "fmt"
+
+// An ApplyFunc is invoked by Apply for each node n in the tree, before
+// and/or after the node's children, using a Cursor describing the current
+// node and providing operations on it. Missing children are skipped, so n
+// is only nil when Apply is given a nil root.
+//
+// The return value of ApplyFunc controls the syntax tree traversal. See
+// Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root, and calling
+// pre and post for each node as described below. Apply returns the syntax
+// tree, possibly modified.
+//
+// If pre is not nil, it is called for each node before the node's children
+// are traversed (pre-order). If pre returns false, no children are traversed,
+// and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre didn't return false, post is
+// called for each node after its children are traversed (post-order). If
+// post returns false, traversal is terminated and Apply returns immediately.
+//
+// Children are traversed in the order Walk visits them, and missing
+// children are skipped.
+//
+// A node that pre replaces, inserts or deletes is not traversed: post is
+// called with the replacement instead of the original, and not at all for a
+// deleted node. Nodes inserted by post are not traversed either.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	holder := &struct{ Node Node }{root}
+
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = holder.Node
+	}()
+
+	application := &application{pre: pre, post: post}
+	application.apply(nil, "Node", -1, root, func(node Node) { holder.Node = node }, nil)
+
+	return holder.Node
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply. Information about the
+// node and its parent is available from the Node, Parent, Name and Index
+// methods.
+type Cursor struct {
+	parent Node
+	name   string
+	index  int
+	node   Node
+
+	replace func(Node)
+	list    *list
+	deleted bool
+}
+
+// list lets a cursor edit the slice its node is in.
+type list struct {
+	index  int
+	step   int
+	insert func(index int, node Node)
+	remove func(index int)
+}
+
+// Node returns the current node.
+func (cursor *Cursor) Node() Node { return cursor.node }
+
+// Parent returns the parent of the current node, or nil for the root.
+func (cursor *Cursor) Parent() Node { return cursor.parent }
+
+// Name returns the name of the parent field that contains the current node,
+// such as "Value" for the value of a let statement, or "Key" for the key of
+// a hash pair.
+func (cursor *Cursor) Name() string { return cursor.name }
+
+// Index reports the index of the current node in the slice of the parent
+// field that contains it, or a value < 0 if the current node is not part of
+// a slice. For the key or value of a hash pair, it is the index of the
+// pair. The index of the current node changes if InsertBefore is called
+// while processing the current node.
+func (cursor *Cursor) Index() int {
+	if cursor.list != nil {
+		return cursor.list.index
+	}
+	return cursor.index
+}
+
+// Replace replaces the current node with node. It panics if node cannot go
+// where the current node is, such as a statement in place of an
+// expression.
+func (cursor *Cursor) Replace(node Node) {
+	cursor.replace(node)
+	cursor.node = node
+}
+
+// Delete deletes the current node from its containing slice. If the current
+// node is not part of a slice, Delete panics.
+func (cursor *Cursor) Delete() {
+	cursor.mustBeInList("Delete")
+	cursor.list.remove(cursor.list.index)
+	cursor.list.step--
+	cursor.deleted = true
+}
+
+// InsertAfter inserts node after the current node in its containing slice.
+// If the current node is not part of a slice, InsertAfter panics. Apply does
+// not walk node.
+func (cursor *Cursor) InsertAfter(node Node) {
+	cursor.mustBeInList("InsertAfter")
+	cursor.list.insert(cursor.list.index+1, node)
+	cursor.list.step++
+}
+
+// InsertBefore inserts node before the current node in its containing slice.
+// If the current node is not part of a slice, InsertBefore panics. Apply
+// does not walk node.
+func (cursor *Cursor) InsertBefore(node Node) {
+	cursor.mustBeInList("InsertBefore")
+	cursor.list.insert(cursor.list.index, node)
+	cursor.list.index++
+}
+
+func (cursor *Cursor) mustBeInList(operation string) {
+	if cursor.list == nil {
+		panic(fmt.Sprintf("%s node not contained in a slice", operation))
+	}
+	if cursor.deleted {
+		panic(fmt.Sprintf("%s after Delete", operation))
+	}
+}
+
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+}
+
+func (application *application) apply(parent Node, name string, index int, node Node, replace func(Node), list *list) {
+	saved := application.cursor
+	defer func() { application.cursor = saved }()
+
+	application.cursor = Cursor{parent: parent, name: name, index: index, node: node, replace: replace, list: list}
+	cursor := &application.cursor
+
+	if application.pre != nil && !application.pre(cursor) {
+		return
+	}
+	if cursor.deleted {
+		return
+	}
+
+	if cursor.node == node {
+		application.applyChildren(node)
+	}
+
+	if application.post != nil && !application.post(cursor) {
+		panic(abort)
+	}
+}
+
+func (application *application) applyChildren(node Node) {
+	switch node := node.(type) {
+	case *Program:
+		applyList(application, node, "Statements", &node.Statements)
+
+	case *LetStatement:
+		if node.Doc != nil {
+			application.apply(node, "Doc", -1, node.Doc, func(n Node) { node.Doc = mustBe[*CommentGroup](n) }, nil)
+		}
+		if node.Name != nil {
+			application.apply(node, "Name", -1, node.Name, func(n Node) { node.Name = mustBe[*Identifier](n) }, nil)
+		}
+		application.applyExpression(node, "Value", node.Value, func(n Node) { node.Value = mustBe[Expression](n) })
+
+	case *ReturnStatement:
+		application.applyExpression(node, "ReturnValue", node.ReturnValue, func(n Node) { node.ReturnValue = mustBe[Expression](n) })
+
+	case *ExpressionStatement:
+		application.applyExpression(node, "Expression", node.Expression, func(n Node) { node.Expression = mustBe[Expression](n) })
+
+	case *BlockStatement:
+		applyList(application, node, "Statements", &node.Statements)
+
+	case *PrefixEpression:
+		application.applyExpression(node, "Rigth", node.Rigth, func(n Node) { node.Rigth = mustBe[Expression](n) })
+
+	case *InfixExpression:
+		application.applyExpression(node, "Left", node.Left, func(n Node) { node.Left = mustBe[Expression](n) })
+		application.applyExpression(node, "Right", node.Right, func(n Node) { node.Right = mustBe[Expression](n) })
+
+	case *IfExpression:
+		application.applyExpression(node, "Condition", node.Condition, func(n Node) { node.Condition = mustBe[Expression](n) })
+		if node.Consequence != nil {
+			application.apply(node, "Consequence", -1, node.Consequence, func(n Node) { node.Consequence = mustBe[*BlockStatement](n) }, nil)
+		}
+		if node.Alternative != nil {
+			application.apply(node, "Alternative", -1, node.Alternative, func(n Node) { node.Alternative = mustBe[*BlockStatement](n) }, nil)
+		}
+
+	case *FunctionLiteral:
+		applyList(application, node, "Parameters", &node.Parameters)
+		if node.Body != nil {
+			application.apply(node, "Body", -1, node.Body, func(n Node) { node.Body = mustBe[*BlockStatement](n) }, nil)
+		}
+
+	case *CallExpression:
+		application.applyExpression(node, "Function", node.Function, func(n Node) { node.Function = mustBe[Expression](n) })
+		applyList(application, node, "Arguments", &node.Arguments)
+
+	case *ArrayLiteral:
+		applyList(application, node, "Elements", &node.Elements)
+
+	case *IndexExpression:
+		application.applyExpression(node, "Left", node.Left, func(n Node) { node.Left = mustBe[Expression](n) })
+		application.applyExpression(node, "Index", node.Index, func(n Node) { node.Index = mustBe[Expression](n) })
+
+	case *HashLiteral:
+		for index := range node.Pairs {
+			pair := &node.Pairs[index]
+			if pair.Key != nil {
+				application.apply(node, "Key", index, pair.Key, func(n Node) { pair.Key = mustBe[Expression](n) }, nil)
+			}
+			if pair.Value != nil {
+				application.apply(node, "Value", index, pair.Value, func(n Node) { pair.Value = mustBe[Expression](n) }, nil)
+			}
+		}
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean,
+		*BadExpression, *BadStatement, *CommentGroup:
+		// leaves
+
+	default:
+		panic(fmt.Sprintf("abstractSyntaxTree.Apply: unexpected node type %T", node))
+	}
+}
+
+func (application *application) applyExpression(parent Node, name string, expression Expression, replace func(Node)) {
+	if expression != nil {
+		application.apply(parent, name, -1, expression, replace, nil)
+	}
+}
+
+// applyList applies to each node of the slice *nodes, letting the cursor
+// insert into and delete from it.
+func applyList[T Node](application *application, parent Node, name string, nodes *[]T) {
+	list := &list{
+		insert: func(index int, node Node) {
+			if node == nil {
+				panic("cannot insert a nil node")
+			}
+			*nodes = append((*nodes)[:index], append([]T{mustBe[T](node)}, (*nodes)[index:]...)...)
+		},
+		remove: func(index int) {
+			*nodes = append((*nodes)[:index], (*nodes)[index+1:]...)
+		},
+	}
+
+	for list.index = 0; list.index < len(*nodes); list.index += list.step {
+		list.step = 1
+
+		node := (*nodes)[list.index]
+		if Node(node) == nil {
+			continue
+		}
+
+		application.apply(parent, name, list.index, node, func(n Node) { (*nodes)[list.index] = mustBe[T](n) }, list)
+	}
+}
+
+// mustBe converts node to the type of the field it replaces, so that a
+// misplaced node fails loudly instead of corrupting the tree.
+func mustBe[T Node](node Node) T {
+	var zero T
+	if node == nil {
+		return zero
+	}
+
+	value, ok := node.(T)
+	if !ok {
+		panic(fmt.Sprintf("cannot use %T as %s", node, fmt.Sprintf("%T", &zero)[1:]))
+	}
+	return value
+}
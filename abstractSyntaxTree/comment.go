@@ -0,0 +1,87 @@
+package abstractSyntaxTree
+
+import (
+	"strings"
+
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+// CommentGroup is a run of comments with no blank line between them.
+type CommentGroup struct {
+	List []token.Trivia
+}
+
+func (commentGroup *CommentGroup) TokenLiteral() string {
+	if len(commentGroup.List) > 0 {
+		return commentGroup.List[0].Text
+	}
+	return ""
+}
+
+func (commentGroup *CommentGroup) String() string {
+	texts := []string{}
+	for _, comment := range commentGroup.List {
+		texts = append(texts, comment.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func (commentGroup *CommentGroup) Pos() token.Position {
+	if len(commentGroup.List) > 0 {
+		return commentGroup.List[0].Span.Start
+	}
+	return token.Position{}
+}
+
+func (commentGroup *CommentGroup) End() token.Position {
+	if len(commentGroup.List) > 0 {
+		return commentGroup.List[len(commentGroup.List)-1].Span.End
+	}
+	return token.Position{}
+}
+
+// Text returns the text of the comments with the comment markers, leading
+// blank lines and trailing whitespace removed, one comment line per line.
+func (commentGroup *CommentGroup) Text() string {
+	lines := []string{}
+
+	for _, comment := range commentGroup.List {
+		text := comment.Text
+
+		switch comment.Kind {
+		case token.LINE_COMMENT:
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(text, "//"), " "))
+		case token.BLOCK_COMMENT:
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+			for _, line := range strings.Split(text, "\n") {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+		}
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// GroupComments splits comments, in source order, into groups separated by
+// blank lines. A shebang line is always a group of its own.
+func GroupComments(comments []token.Trivia) []*CommentGroup {
+	groups := []*CommentGroup{}
+
+	for index, comment := range comments {
+		if index == 0 || comment.Span.Start.Line > comments[index-1].Span.End.Line+1 ||
+			comments[index-1].Kind == token.SHEBANG {
+			groups = append(groups, &CommentGroup{})
+		}
+		current := groups[len(groups)-1]
+		current.List = append(current.List, comment)
+	}
+
+	return groups
+}
@@ -0,0 +1,689 @@
+package abstractSyntaxTree
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+// EncodeJSON returns node as an indented JSON document. Every node is an
+// object whose "kind" names its type and whose "span", when the node has a
+// position, gives its start and end as {"line", "column", "offset"}. The
+// other fields hold the node's literal values and its children, in the
+// order they appear in the source:
+//
+//	Program              statements, comments
+//	LetStatement         doc (optional), name, value
+//	ReturnStatement      value (optional)
+//	ExpressionStatement  expression
+//	BlockStatement       statements
+//	Identifier           name
+//	IntegerLiteral       value, literal
+//	FloatLiteral         value, literal
+//	StringLiteral        value
+//	Boolean              value
+//	PrefixExpression     operator, operand
+//	InfixExpression      operator, left, right
+//	IfExpression         condition, consequence, alternative (optional)
+//	FunctionLiteral      parameters, body
+//	CallExpression       function, arguments
+//	ArrayLiteral         elements
+//	IndexExpression      left, index
+//	HashLiteral          pairs, each a {"key", "value"} object
+//	BadExpression        (nothing else)
+//	BadStatement         (nothing else)
+//	CommentGroup         comments, each a {"kind", "text", "span"} object
+//	                     whose kind is "line", "block" or "shebang"
+//
+// Optional children are left out when they are missing.
+func EncodeJSON(node Node) ([]byte, error) {
+	object, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(object, "", "  ")
+}
+
+// jsonObject is a JSON object that keeps its fields in order, so that the
+// encoding is stable and starts with the kind.
+type jsonObject []jsonField
+
+type jsonField struct {
+	name  string
+	value any
+}
+
+func (object jsonObject) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+
+	out.WriteByte('{')
+	for index, field := range object {
+		if index > 0 {
+			out.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(field.name)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+
+	return out.Bytes(), nil
+}
+
+var triviaKinds = map[token.TriviaKind]string{
+	token.LINE_COMMENT:  "line",
+	token.BLOCK_COMMENT: "block",
+	token.SHEBANG:       "shebang",
+}
+
+func encodeSpan(start, end token.Position) any {
+	if !start.IsValid() {
+		return nil
+	}
+
+	position := func(position token.Position) jsonObject {
+		return jsonObject{{"line", position.Line}, {"column", position.Column}, {"offset", position.Offset}}
+	}
+	return jsonObject{{"start", position(start)}, {"end", position(end)}}
+}
+
+func encodeNode(node Node) (jsonObject, error) {
+	encoder := &jsonEncoder{}
+	object := encoder.encode(node)
+	return object, encoder.err
+}
+
+type jsonEncoder struct {
+	err error
+}
+
+func (encoder *jsonEncoder) encode(node Node) jsonObject {
+	var kind string
+	var fields jsonObject
+
+	switch node := node.(type) {
+	case *Program:
+		kind = "Program"
+		fields = jsonObject{
+			{"statements", encodeList(encoder, node.Statements)},
+			{"comments", encodeList(encoder, node.Comments)},
+		}
+
+	case *LetStatement:
+		kind = "LetStatement"
+		if node.Doc != nil {
+			fields = append(fields, jsonField{"doc", encoder.encode(node.Doc)})
+		}
+		fields = append(fields, jsonField{"name", encoder.encodeChild(node.Name)}, jsonField{"value", encoder.encodeChild(node.Value)})
+
+	case *ReturnStatement:
+		kind = "ReturnStatement"
+		if node.ReturnValue != nil {
+			fields = jsonObject{{"value", encoder.encode(node.ReturnValue)}}
+		}
+
+	case *ExpressionStatement:
+		kind = "ExpressionStatement"
+		fields = jsonObject{{"expression", encoder.encodeChild(node.Expression)}}
+
+	case *BlockStatement:
+		kind = "BlockStatement"
+		fields = jsonObject{{"statements", encodeList(encoder, node.Statements)}}
+
+	case *Identifier:
+		kind = "Identifier"
+		fields = jsonObject{{"name", node.Value}}
+
+	case *IntegerLiteral:
+		kind = "IntegerLiteral"
+		fields = jsonObject{{"value", node.Value}, {"literal", node.Token.Literal}}
+
+	case *FloatLiteral:
+		kind = "FloatLiteral"
+		fields = jsonObject{{"value", node.Value}, {"literal", node.Token.Literal}}
+
+	case *StringLiteral:
+		kind = "StringLiteral"
+		fields = jsonObject{{"value", node.Value}}
+
+	case *Boolean:
+		kind = "Boolean"
+		fields = jsonObject{{"value", node.Value}}
+
+	case *PrefixEpression:
+		kind = "PrefixExpression"
+		fields = jsonObject{{"operator", node.Operator}, {"operand", encoder.encodeChild(node.Rigth)}}
+
+	case *InfixExpression:
+		kind = "InfixExpression"
+		fields = jsonObject{
+			{"operator", node.Operator},
+			{"left", encoder.encodeChild(node.Left)},
+			{"right", encoder.encodeChild(node.Right)},
+		}
+
+	case *IfExpression:
+		kind = "IfExpression"
+		fields = jsonObject{{"condition", encoder.encodeChild(node.Condition)}, {"consequence", encoder.encodeChild(node.Consequence)}}
+		if node.Alternative != nil {
+			fields = append(fields, jsonField{"alternative", encoder.encode(node.Alternative)})
+		}
+
+	case *FunctionLiteral:
+		kind = "FunctionLiteral"
+		fields = jsonObject{{"parameters", encodeList(encoder, node.Parameters)}, {"body", encoder.encodeChild(node.Body)}}
+
+	case *CallExpression:
+		kind = "CallExpression"
+		fields = jsonObject{{"function", encoder.encodeChild(node.Function)}, {"arguments", encodeList(encoder, node.Arguments)}}
+
+	case *ArrayLiteral:
+		kind = "ArrayLiteral"
+		fields = jsonObject{{"elements", encodeList(encoder, node.Elements)}}
+
+	case *IndexExpression:
+		kind = "IndexExpression"
+		fields = jsonObject{{"left", encoder.encodeChild(node.Left)}, {"index", encoder.encodeChild(node.Index)}}
+
+	case *HashLiteral:
+		kind = "HashLiteral"
+		pairs := []jsonObject{}
+		for _, pair := range node.Pairs {
+			pairs = append(pairs, jsonObject{{"key", encoder.encodeChild(pair.Key)}, {"value", encoder.encodeChild(pair.Value)}})
+		}
+		fields = jsonObject{{"pairs", pairs}}
+
+	case *BadExpression:
+		kind = "BadExpression"
+
+	case *BadStatement:
+		kind = "BadStatement"
+
+	case *CommentGroup:
+		kind = "CommentGroup"
+		comments := []jsonObject{}
+		for _, comment := range node.List {
+			object := jsonObject{{"kind", triviaKinds[comment.Kind]}, {"text", comment.Text}}
+			if span := encodeSpan(comment.Span.Start, comment.Span.End); span != nil {
+				object = append(object, jsonField{"span", span})
+			}
+			comments = append(comments, object)
+		}
+		fields = jsonObject{{"comments", comments}}
+
+	default:
+		if encoder.err == nil {
+			encoder.err = fmt.Errorf("abstractSyntaxTree.EncodeJSON: unexpected node type %T", node)
+		}
+		return nil
+	}
+
+	object := jsonObject{{"kind", kind}}
+	if span := encodeSpan(node.Pos(), node.End()); span != nil {
+		object = append(object, jsonField{"span", span})
+	}
+
+	return append(object, fields...)
+}
+
+// encodeChild encodes a child that may be missing, as null.
+func (encoder *jsonEncoder) encodeChild(node Node) any {
+	if isNil(node) {
+		return nil
+	}
+	return encoder.encode(node)
+}
+
+func encodeList[T Node](encoder *jsonEncoder, nodes []T) []any {
+	list := []any{}
+	for _, node := range nodes {
+		list = append(list, encoder.encodeChild(node))
+	}
+	return list
+}
+
+// isNil reports whether node is nil, including a nil pointer stored in the
+// interface, as a missing Name or Body is.
+func isNil(node Node) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *Identifier:
+		return node == nil
+	case *BlockStatement:
+		return node == nil
+	case *CommentGroup:
+		return node == nil
+	}
+	return false
+}
+
+// DecodeJSON reads a node from a document in the form EncodeJSON writes,
+// such as a whole *Program. Tokens are rebuilt from the kinds, values and
+// spans, so the result can be printed, formatted and run like a parsed
+// program. Spans may be left out, for example by a tool that generates
+// code. Names, operators and literals are checked as the parser would
+// check them, and a literal must agree with its value.
+func DecodeJSON(data []byte) (Node, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if isNull(raw) {
+		return nil, fmt.Errorf("expected a node, got null")
+	}
+
+	decoder := &jsonDecoder{}
+	node := decoder.node(raw, "")
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+
+	return node, nil
+}
+
+type jsonDecoder struct {
+	err error
+}
+
+func (decoder *jsonDecoder) fail(path string, format string, arguments ...any) {
+	if decoder.err == nil {
+		if path == "" {
+			path = "document"
+		}
+		decoder.err = fmt.Errorf("%s: %s", path, fmt.Sprintf(format, arguments...))
+	}
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// fieldPath returns the path of a field, as in `statements[0].value`, for
+// error messages.
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonReader reads the fields of a JSON object.
+type jsonReader struct {
+	decoder *jsonDecoder
+	path    string
+	fields  map[string]json.RawMessage
+}
+
+func (decoder *jsonDecoder) reader(raw json.RawMessage, path string) *jsonReader {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		decoder.fail(path, "expected an object")
+	}
+	return &jsonReader{decoder: decoder, path: path, fields: fields}
+}
+
+// value decodes the field name into target, reporting it when it is
+// missing unless optional.
+func (reader *jsonReader) value(name string, target any, optional bool) {
+	raw, ok := reader.fields[name]
+	if !ok || isNull(raw) {
+		if !optional {
+			reader.decoder.fail(fieldPath(reader.path, name), "missing")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(raw, target); err != nil {
+		reader.decoder.fail(fieldPath(reader.path, name), "%s", err)
+	}
+}
+
+func (reader *jsonReader) string(name string) string {
+	var value string
+	reader.value(name, &value, false)
+	return value
+}
+
+func (reader *jsonReader) span() token.Span {
+	var span struct {
+		Start, End struct{ Line, Column, Offset int }
+	}
+	reader.value("span", &span, true)
+
+	return token.Span{
+		Start: token.Position{Line: span.Start.Line, Column: span.Start.Column, Offset: span.Start.Offset},
+		End:   token.Position{Line: span.End.Line, Column: span.End.Column, Offset: span.End.Offset},
+	}
+}
+
+func (reader *jsonReader) node(name string, optional bool) Node {
+	raw, ok := reader.fields[name]
+	if !ok || isNull(raw) {
+		if !optional {
+			reader.decoder.fail(fieldPath(reader.path, name), "missing")
+		}
+		return nil
+	}
+	return reader.decoder.node(raw, fieldPath(reader.path, name))
+}
+
+func (reader *jsonReader) list(name string) []json.RawMessage {
+	var list []json.RawMessage
+	reader.value(name, &list, true)
+	return list
+}
+
+func (reader *jsonReader) expression(name string) Expression {
+	return decodeAs[Expression](reader.decoder, reader.node(name, false), fieldPath(reader.path, name))
+}
+
+func (reader *jsonReader) block(name string, optional bool) *BlockStatement {
+	return decodeAs[*BlockStatement](reader.decoder, reader.node(name, optional), fieldPath(reader.path, name))
+}
+
+// decodeAs checks that a decoded child is of the type its field needs.
+func decodeAs[T Node](decoder *jsonDecoder, node Node, path string) T {
+	var zero T
+	if node == nil {
+		return zero
+	}
+
+	value, ok := node.(T)
+	if !ok {
+		decoder.fail(path, "%s cannot go here", kindOf(node))
+	}
+	return value
+}
+
+func decodeList[T Node](reader *jsonReader, name string) []T {
+	nodes := []T{}
+	for index, raw := range reader.list(name) {
+		path := fmt.Sprintf("%s[%d]", fieldPath(reader.path, name), index)
+		if isNull(raw) {
+			reader.decoder.fail(path, "missing")
+			continue
+		}
+		nodes = append(nodes, decodeAs[T](reader.decoder, reader.decoder.node(raw, path), path))
+	}
+	return nodes
+}
+
+// kindOf returns the kind EncodeJSON gives node.
+func kindOf(node Node) string {
+	if _, ok := node.(*PrefixEpression); ok {
+		return "PrefixExpression"
+	}
+	return fmt.Sprintf("%T", node)[len("*abstractSyntaxTree."):]
+}
+
+func (decoder *jsonDecoder) node(raw json.RawMessage, path string) Node {
+	if decoder.err != nil {
+		return nil
+	}
+
+	reader := decoder.reader(raw, path)
+	kind := reader.string("kind")
+	span := reader.span()
+
+	switch kind {
+	case "Program":
+		program := &Program{Statements: decodeList[Statement](reader, "statements")}
+		program.Comments = decodeList[*CommentGroup](reader, "comments")
+		return program
+
+	case "LetStatement":
+		statement := &LetStatement{Token: tokenAt(token.LET, "let", span.Start)}
+		statement.Doc = decodeAs[*CommentGroup](decoder, reader.node("doc", true), fieldPath(path, "doc"))
+		statement.Name = decodeAs[*Identifier](decoder, reader.node("name", false), fieldPath(path, "name"))
+		statement.Value = reader.expression("value")
+		return statement
+
+	case "ReturnStatement":
+		statement := &ReturnStatement{Token: tokenAt(token.RETURN, "return", span.Start)}
+		statement.ReturnValue = decodeAs[Expression](decoder, reader.node("value", true), fieldPath(path, "value"))
+		return statement
+
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: token.Token{Span: span}, Expression: reader.expression("expression")}
+
+	case "BlockStatement":
+		return &BlockStatement{
+			Token:      tokenAt(token.LEFT_BRACE, "{", span.Start),
+			Statements: decodeList[Statement](reader, "statements"),
+			RightBrace: tokenBefore(token.RIGHT_BRACE, "}", span.End),
+		}
+
+	case "Identifier":
+		name := reader.string("name")
+		if !lexesAs(name, token.IDENT) {
+			decoder.fail(fieldPath(path, "name"), "%q is not an identifier", name)
+		}
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Span: span}, Value: name}
+
+	case "IntegerLiteral":
+		literal := &IntegerLiteral{}
+		reader.value("value", &literal.Value, false)
+		reader.value("literal", &literal.Token.Literal, true)
+		if literal.Token.Literal == "" {
+			literal.Token.Literal = strconv.FormatInt(literal.Value, 10)
+		} else if value, ok := integerValue(literal.Token.Literal); !ok || value != literal.Value {
+			decoder.fail(fieldPath(path, "literal"), "%q does not match value %d", literal.Token.Literal, literal.Value)
+		}
+		literal.Token.Type, literal.Token.Span = token.INT, span
+		return literal
+
+	case "FloatLiteral":
+		literal := &FloatLiteral{}
+		reader.value("value", &literal.Value, false)
+		reader.value("literal", &literal.Token.Literal, true)
+		if literal.Token.Literal == "" {
+			literal.Token.Literal = strconv.FormatFloat(literal.Value, 'g', -1, 64)
+			if !strings.ContainsAny(literal.Token.Literal, ".e") {
+				literal.Token.Literal += ".0"
+			}
+		} else if value, ok := floatValue(literal.Token.Literal); !ok || value != literal.Value {
+			decoder.fail(fieldPath(path, "literal"), "%q does not match value %g", literal.Token.Literal, literal.Value)
+		}
+		literal.Token.Type, literal.Token.Span = token.FLOAT, span
+		return literal
+
+	case "StringLiteral":
+		literal := &StringLiteral{}
+		reader.value("value", &literal.Value, false)
+		literal.Token = token.Token{Type: token.STRING, Literal: literal.Value, Span: span}
+		return literal
+
+	case "Boolean":
+		boolean := &Boolean{}
+		reader.value("value", &boolean.Value, false)
+		boolean.Token = token.Token{Type: token.FALSE, Literal: "false", Span: span}
+		if boolean.Value {
+			boolean.Token.Type, boolean.Token.Literal = token.TRUE, "true"
+		}
+		return boolean
+
+	case "PrefixExpression":
+		operator := reader.string("operator")
+		if !prefixOperators[token.TokenType(operator)] {
+			decoder.fail(fieldPath(path, "operator"), "unknown prefix operator %q", operator)
+		}
+		return &PrefixEpression{
+			Token:    tokenAt(token.TokenType(operator), operator, span.Start),
+			Operator: operator,
+			Rigth:    reader.expression("operand"),
+		}
+
+	case "InfixExpression":
+		expression := &InfixExpression{Left: reader.expression("left"), Operator: reader.string("operator")}
+		if !infixOperators[token.TokenType(expression.Operator)] {
+			decoder.fail(fieldPath(path, "operator"), "unknown infix operator %q", expression.Operator)
+		}
+		expression.Right = reader.expression("right")
+		expression.Token = token.Token{Type: token.TokenType(expression.Operator), Literal: expression.Operator}
+		return expression
+
+	case "IfExpression":
+		return &IfExpression{
+			Token:       tokenAt(token.IF, "if", span.Start),
+			Condition:   reader.expression("condition"),
+			Consequence: reader.block("consequence", false),
+			Alternative: reader.block("alternative", true),
+		}
+
+	case "FunctionLiteral":
+		return &FunctionLiteral{
+			Token:      tokenAt(token.FUNCTION, "fn", span.Start),
+			Parameters: decodeList[*Identifier](reader, "parameters"),
+			Body:       reader.block("body", false),
+		}
+
+	case "CallExpression":
+		return &CallExpression{
+			Token:            token.Token{Type: token.LEFT_PARENTHESIS, Literal: "("},
+			Function:         reader.expression("function"),
+			Arguments:        decodeList[Expression](reader, "arguments"),
+			RightParenthesis: tokenBefore(token.RIGHT_PARENTHESIS, ")", span.End),
+		}
+
+	case "ArrayLiteral":
+		return &ArrayLiteral{
+			Token:        tokenAt(token.LEFT_BRACKET, "[", span.Start),
+			Elements:     decodeList[Expression](reader, "elements"),
+			RightBracket: tokenBefore(token.RIGHT_BRACKET, "]", span.End),
+		}
+
+	case "IndexExpression":
+		return &IndexExpression{
+			Token:        token.Token{Type: token.LEFT_BRACKET, Literal: "["},
+			Left:         reader.expression("left"),
+			Index:        reader.expression("index"),
+			RightBracket: tokenBefore(token.RIGHT_BRACKET, "]", span.End),
+		}
+
+	case "HashLiteral":
+		hash := &HashLiteral{Token: tokenAt(token.LEFT_BRACE, "{", span.Start), Pairs: []HashPair{}}
+		for index, raw := range reader.list("pairs") {
+			pair := decoder.reader(raw, fmt.Sprintf("%s[%d]", fieldPath(path, "pairs"), index))
+			hash.Pairs = append(hash.Pairs, HashPair{Key: pair.expression("key"), Value: pair.expression("value")})
+		}
+		hash.RightBrace = tokenBefore(token.RIGHT_BRACE, "}", span.End)
+		return hash
+
+	case "BadExpression":
+		return &BadExpression{Token: token.Token{Span: span}, From: span.Start, To: span.End}
+
+	case "BadStatement":
+		return &BadStatement{Token: token.Token{Span: span}, From: span.Start, To: span.End}
+
+	case "CommentGroup":
+		group := &CommentGroup{}
+		for index, raw := range reader.list("comments") {
+			comment := decoder.reader(raw, fmt.Sprintf("%s[%d]", fieldPath(path, "comments"), index))
+			trivia := token.Trivia{Text: comment.string("text"), Span: comment.span()}
+
+			kind := comment.string("kind")
+			known := false
+			for triviaKind, name := range triviaKinds {
+				if name == kind {
+					trivia.Kind, known = triviaKind, true
+				}
+			}
+			if !known && decoder.err == nil {
+				decoder.fail(fieldPath(comment.path, "kind"), "unknown comment kind %q", kind)
+			}
+
+			group.List = append(group.List, trivia)
+		}
+		return group
+
+	default:
+		if decoder.err == nil {
+			decoder.fail(path, "unknown node kind %q", kind)
+		}
+		return nil
+	}
+}
+
+var prefixOperators = map[token.TokenType]bool{token.BANG: true, token.MINUS: true}
+
+var infixOperators = map[token.TokenType]bool{
+	token.ADD: true, token.MINUS: true, token.ASTERISK: true, token.SLASH: true, token.PERCENT: true,
+	token.POWER: true, token.EQUALS: true, token.NOT_EQUALS: true, token.LESS_THAN: true,
+	token.GREATER_THAN: true, token.LESS_EQUAL: true, token.GREATER_EQUAL: true,
+	token.AND: true, token.OR: true,
+}
+
+// lexesAs reports whether the lexer reads text as a single token of
+// tokenType, written exactly as text.
+func lexesAs(text string, tokenType token.TokenType) bool {
+	source := lexer.NewLexer(text)
+	first := source.NextToken()
+	return first.Type == tokenType && first.Literal == text &&
+		source.NextToken().Type == token.EOF && len(source.Errors()) == 0
+}
+
+// integerValue returns the value of an integer literal, which may carry a
+// leading `-` for a literal built outside the parser.
+func integerValue(literal string) (int64, bool) {
+	unsigned, negative := strings.CutPrefix(literal, "-")
+	if !lexesAs(unsigned, token.INT) {
+		return 0, false
+	}
+
+	digits, base := lexer.IntegerDigits(unsigned)
+	if negative {
+		digits = "-" + digits
+	}
+	value, err := strconv.ParseInt(digits, base, 64)
+	return value, err == nil
+}
+
+func floatValue(literal string) (float64, bool) {
+	unsigned, _ := strings.CutPrefix(literal, "-")
+	if !lexesAs(unsigned, token.FLOAT) {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(literal, "_", ""), 64)
+	return value, err == nil
+}
+
+// tokenAt returns a token that starts at start, or has no position when
+// start is not valid.
+func tokenAt(tokenType token.TokenType, literal string, start token.Position) token.Token {
+	currentToken := token.Token{Type: tokenType, Literal: literal}
+	if start.IsValid() {
+		end := start
+		end.Offset += len(literal)
+		end.Column += utf8.RuneCountInString(literal)
+		currentToken.Span = token.Span{Start: start, End: end}
+	}
+	return currentToken
+}
+
+// tokenBefore returns a closing delimiter that ends at end.
+func tokenBefore(tokenType token.TokenType, literal string, end token.Position) token.Token {
+	currentToken := token.Token{Type: tokenType, Literal: literal}
+	if end.IsValid() {
+		start := end
+		start.Offset -= len(literal)
+		start.Column -= len(literal)
+		currentToken.Span = token.Span{Start: start, End: end}
+	}
+	return currentToken
+}
@@ -0,0 +1,127 @@
+package abstractSyntaxTree
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SEXP_WIDTH is the width up to which SExpression keeps a node on one line.
+const SEXP_WIDTH = 80
+
+// SExpression returns node as an S-expression, for snapshot tests and for
+// reading the shape of a tree. Each node is a list of its kind, from the
+// table of EncodeJSON, then its literal values, then its children in source
+// order; hash pairs are (HashPair key value) lists. A list that does not
+// fit in SEXP_WIDTH columns puts each child on a line of its own, indented
+// by two spaces. Spans and comments are left out.
+func SExpression(node Node) string {
+	var out strings.Builder
+	writeSExpression(&out, buildSExpression(node), 0)
+	return out.String()
+}
+
+// sexp is a list whose head holds the kind and the literal values.
+type sexp struct {
+	head     []string
+	children []*sexp
+}
+
+func buildSExpression(node Node) *sexp {
+	if isNil(node) {
+		return &sexp{head: []string{"nil"}}
+	}
+
+	expression := &sexp{head: []string{kindOf(node)}}
+	add := func(nodes ...Node) {
+		for _, node := range nodes {
+			expression.children = append(expression.children, buildSExpression(node))
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			add(statement)
+		}
+	case *LetStatement:
+		add(node.Name, node.Value)
+	case *ReturnStatement:
+		if node.ReturnValue != nil {
+			add(node.ReturnValue)
+		}
+	case *ExpressionStatement:
+		add(node.Expression)
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			add(statement)
+		}
+	case *Identifier:
+		expression.head = append(expression.head, node.Value)
+	case *IntegerLiteral:
+		expression.head = append(expression.head, strconv.FormatInt(node.Value, 10))
+	case *FloatLiteral:
+		expression.head = append(expression.head, strconv.FormatFloat(node.Value, 'g', -1, 64))
+	case *StringLiteral:
+		expression.head = append(expression.head, Quote(node.Value))
+	case *Boolean:
+		expression.head = append(expression.head, strconv.FormatBool(node.Value))
+	case *PrefixEpression:
+		expression.head = append(expression.head, node.Operator)
+		add(node.Rigth)
+	case *InfixExpression:
+		expression.head = append(expression.head, node.Operator)
+		add(node.Left, node.Right)
+	case *IfExpression:
+		add(node.Condition, node.Consequence)
+		if node.Alternative != nil {
+			add(node.Alternative)
+		}
+	case *FunctionLiteral:
+		for _, parameter := range node.Parameters {
+			add(parameter)
+		}
+		add(node.Body)
+	case *CallExpression:
+		add(node.Function)
+		for _, argument := range node.Arguments {
+			add(argument)
+		}
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			add(element)
+		}
+	case *IndexExpression:
+		add(node.Left, node.Index)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			expression.children = append(expression.children, &sexp{
+				head:     []string{"HashPair"},
+				children: []*sexp{buildSExpression(pair.Key), buildSExpression(pair.Value)},
+			})
+		}
+	}
+
+	return expression
+}
+
+func (expression *sexp) String() string {
+	parts := append([]string{}, expression.head...)
+	for _, child := range expression.children {
+		parts = append(parts, child.String())
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func writeSExpression(out *strings.Builder, expression *sexp, indent int) {
+	if flat := expression.String(); indent+len(flat) <= SEXP_WIDTH || len(expression.children) == 0 {
+		out.WriteString(flat)
+		return
+	}
+
+	out.WriteString("(" + strings.Join(expression.head, " "))
+	for _, child := range expression.children {
+		out.WriteString("\n" + strings.Repeat(" ", indent+2))
+		writeSExpression(out, child, indent+2)
+	}
+	out.WriteString(")")
+}
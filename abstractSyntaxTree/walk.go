@@ -0,0 +1,130 @@
+package abstractSyntaxTree
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order, visiting children in
+// the order they appear in the source. It starts by calling
+// visitor.Visit(node); node must not be nil. Missing children, such as the
+// value of a `return;`, are skipped. The doc comment of a let statement is
+// visited before its name; the other comments of a program are not visited.
+func Walk(visitor Visitor, node Node) {
+	if visitor = visitor.Visit(node); visitor == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		walkStatements(visitor, node.Statements)
+
+	case *LetStatement:
+		if node.Doc != nil {
+			Walk(visitor, node.Doc)
+		}
+		if node.Name != nil {
+			Walk(visitor, node.Name)
+		}
+		walkExpression(visitor, node.Value)
+
+	case *ReturnStatement:
+		walkExpression(visitor, node.ReturnValue)
+
+	case *ExpressionStatement:
+		walkExpression(visitor, node.Expression)
+
+	case *BlockStatement:
+		walkStatements(visitor, node.Statements)
+
+	case *PrefixEpression:
+		walkExpression(visitor, node.Rigth)
+
+	case *InfixExpression:
+		walkExpression(visitor, node.Left)
+		walkExpression(visitor, node.Right)
+
+	case *IfExpression:
+		walkExpression(visitor, node.Condition)
+		if node.Consequence != nil {
+			Walk(visitor, node.Consequence)
+		}
+		if node.Alternative != nil {
+			Walk(visitor, node.Alternative)
+		}
+
+	case *FunctionLiteral:
+		for _, parameter := range node.Parameters {
+			Walk(visitor, parameter)
+		}
+		if node.Body != nil {
+			Walk(visitor, node.Body)
+		}
+
+	case *CallExpression:
+		walkExpression(visitor, node.Function)
+		walkExpressions(visitor, node.Arguments)
+
+	case *ArrayLiteral:
+		walkExpressions(visitor, node.Elements)
+
+	case *IndexExpression:
+		walkExpression(visitor, node.Left)
+		walkExpression(visitor, node.Index)
+
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			walkExpression(visitor, pair.Key)
+			walkExpression(visitor, pair.Value)
+		}
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean,
+		*BadExpression, *BadStatement, *CommentGroup:
+		// leaves
+
+	default:
+		panic(fmt.Sprintf("abstractSyntaxTree.Walk: unexpected node type %T", node))
+	}
+
+	visitor.Visit(nil)
+}
+
+func walkExpression(visitor Visitor, expression Expression) {
+	if expression != nil {
+		Walk(visitor, expression)
+	}
+}
+
+func walkExpressions(visitor Visitor, expressions []Expression) {
+	for _, expression := range expressions {
+		walkExpression(visitor, expression)
+	}
+}
+
+func walkStatements(visitor Visitor, statements []Statement) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(visitor, statement)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order like Walk. It calls
+// f(node) for each node; if f returns true, Inspect invokes f recursively
+// for each of the children of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,278 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+type Instructions []byte
+
+func (instructions Instructions) String() string {
+	var out bytes.Buffer
+
+	offset := 0
+	for offset < len(instructions) {
+		definition, err := Lookup(instructions[offset])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			offset++
+			continue
+		}
+
+		operands, read := ReadOperands(definition, instructions[offset+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", offset, instructions.formatInstruction(definition, operands))
+
+		offset += 1 + read
+	}
+
+	return out.String()
+}
+
+func (instructions Instructions) formatInstruction(definition *Definition, operands []int) string {
+	operandCount := len(definition.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return definition.Name
+	case 1:
+		return fmt.Sprintf("%s %d", definition.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", definition.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", definition.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJump
+	OpJumpNotTruthy
+	OpJumpTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+
+	OpArray
+	OpHash
+	OpIndex
+
+	OpCall
+	OpReturnValue
+	OpReturn
+
+	OpClosure
+	OpGetFree
+	OpCaptureLocal
+	OpCaptureFree
+)
+
+// Definition describes an opcode for humans and for the encoder: its name,
+// and the width in bytes and meaning of each of its operands.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+	OperandNames  []string
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}, []string{"constant"}},
+	OpPop:      {"OpPop", []int{}, []string{}},
+
+	OpAdd: {"OpAdd", []int{}, []string{}},
+	OpSub: {"OpSub", []int{}, []string{}},
+	OpMul: {"OpMul", []int{}, []string{}},
+	OpDiv: {"OpDiv", []int{}, []string{}},
+	OpMod: {"OpMod", []int{}, []string{}},
+	OpPow: {"OpPow", []int{}, []string{}},
+
+	OpEqual:        {"OpEqual", []int{}, []string{}},
+	OpNotEqual:     {"OpNotEqual", []int{}, []string{}},
+	OpLessThan:     {"OpLessThan", []int{}, []string{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}, []string{}},
+	OpLessEqual:    {"OpLessEqual", []int{}, []string{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}, []string{}},
+
+	OpMinus: {"OpMinus", []int{}, []string{}},
+	OpBang:  {"OpBang", []int{}, []string{}},
+
+	OpTrue:  {"OpTrue", []int{}, []string{}},
+	OpFalse: {"OpFalse", []int{}, []string{}},
+	OpNull:  {"OpNull", []int{}, []string{}},
+
+	OpJump:          {"OpJump", []int{2}, []string{"target"}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}, []string{"target"}},
+	OpJumpTruthy:    {"OpJumpTruthy", []int{2}, []string{"target"}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}, []string{"global"}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}, []string{"global"}},
+	OpGetLocal:  {"OpGetLocal", []int{1}, []string{"local"}},
+	OpSetLocal:  {"OpSetLocal", []int{1}, []string{"local"}},
+
+	OpArray: {"OpArray", []int{2}, []string{"elements"}},
+	OpHash:  {"OpHash", []int{2}, []string{"elements"}},
+	OpIndex: {"OpIndex", []int{}, []string{}},
+
+	OpCall:        {"OpCall", []int{1}, []string{"arguments"}},
+	OpReturnValue: {"OpReturnValue", []int{}, []string{}},
+	OpReturn:      {"OpReturn", []int{}, []string{}},
+
+	// OpClosure takes the constant index of a function and the number of
+	// free variables to capture from the top of the stack, where
+	// OpCaptureLocal and OpCaptureFree put the cells that hold them.
+	OpClosure:      {"OpClosure", []int{2, 1}, []string{"constant", "free"}},
+	OpGetFree:      {"OpGetFree", []int{1}, []string{"free"}},
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}, []string{"local"}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}, []string{"free"}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	definition, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return definition, nil
+}
+
+// Make encodes an instruction. Operands are written big-endian, each with the
+// width its definition gives it.
+func Make(op Opcode, operands ...int) []byte {
+	definition, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLength := 1
+	for _, width := range definition.OperandWidths {
+		instructionLength += width
+	}
+
+	instruction := make([]byte, instructionLength)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for index, operand := range operands {
+		width := definition.OperandWidths[index]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands that follow an opcode and returns them
+// with the number of bytes they took.
+func ReadOperands(definition *Definition, instructions Instructions) ([]int, int) {
+	operands := make([]int, len(definition.OperandWidths))
+	offset := 0
+
+	for index, width := range definition.OperandWidths {
+		switch width {
+		case 2:
+			operands[index] = int(ReadUint16(instructions[offset:]))
+		case 1:
+			operands[index] = int(ReadUint8(instructions[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// FormatOperands prints an instruction with each operand labelled by its
+// name, as in "OpClosure constant=3 free=1".
+func FormatOperands(definition *Definition, operands []int) string {
+	var out bytes.Buffer
+
+	out.WriteString(definition.Name)
+	for index, operand := range operands {
+		fmt.Fprintf(&out, " %s=%d", definition.OperandNames[index], operand)
+	}
+
+	return out.String()
+}
+
+// LineTable maps instruction offsets back to source lines. An entry covers
+// the instructions from its Offset up to the Offset of the next entry.
+type LineTable []LineEntry
+
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// Add records that the instruction at offset came from line, unless the
+// table already attributes the preceding instructions to that line.
+func (lines LineTable) Add(offset, line int) LineTable {
+	if len(lines) > 0 && lines[len(lines)-1].Line == line {
+		return lines
+	}
+	return append(lines, LineEntry{Offset: offset, Line: line})
+}
+
+// Truncate drops the entries for instructions at offset and after, for when
+// those instructions are taken back.
+func (lines LineTable) Truncate(offset int) LineTable {
+	for len(lines) > 0 && lines[len(lines)-1].Offset >= offset {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Line returns the source line of the instruction at offset, or 0 when it
+// is unknown.
+func (lines LineTable) Line(offset int) int {
+	index := sort.Search(len(lines), func(i int) bool { return lines[i].Offset > offset })
+	if index == 0 {
+		return 0
+	}
+	return lines[index-1].Line
+}
+
+func ReadUint16(instructions Instructions) uint16 {
+	return binary.BigEndian.Uint16(instructions)
+}
+
+func ReadUint8(instructions Instructions) uint8 {
+	return uint8(instructions[0])
+}
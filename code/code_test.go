@@ -0,0 +1,139 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != tt.expected[i] {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpJumpTruthy, 3),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpJumpTruthy 3
+0012 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, instruction := range instructions {
+		concatted = append(concatted, instruction...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		definition, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(definition, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestFormatOperands(t *testing.T) {
+	definition, err := Lookup(byte(OpClosure))
+	if err != nil {
+		t.Fatalf("definition not found: %q\n", err)
+	}
+
+	if formatted := FormatOperands(definition, []int{3, 1}); formatted != "OpClosure constant=3 free=1" {
+		t.Errorf("FormatOperands wrong. got=%q", formatted)
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	lines := LineTable{}
+	lines = lines.Add(0, 1)
+	lines = lines.Add(3, 1)
+	lines = lines.Add(6, 2)
+	lines = lines.Add(9, 4)
+
+	if len(lines) != 3 {
+		t.Fatalf("lines has wrong length. want=3, got=%d", len(lines))
+	}
+
+	tests := []struct {
+		offset   int
+		expected int
+	}{
+		{0, 1},
+		{5, 1},
+		{6, 2},
+		{8, 2},
+		{9, 4},
+		{100, 4},
+	}
+
+	for _, tt := range tests {
+		if line := lines.Line(tt.offset); line != tt.expected {
+			t.Errorf("line at %d wrong. want=%d, got=%d", tt.offset, tt.expected, line)
+		}
+	}
+
+	lines = lines.Truncate(6)
+	if line := lines.Line(9); line != 1 {
+		t.Errorf("line after Truncate wrong. want=1, got=%d", line)
+	}
+	if line := (LineTable{}).Line(0); line != 0 {
+		t.Errorf("line in empty table wrong. want=0, got=%d", line)
+	}
+}
@@ -0,0 +1,644 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+const (
+	// MAX_LOCALS is the highest local slot an OpGetLocal operand can address.
+	MAX_LOCALS = 255
+	// MAX_FREE is the most free variables an OpClosure operand can count.
+	MAX_FREE = 255
+	// MAX_CONSTANTS is the highest constant index an OpConstant operand can
+	// address.
+	MAX_CONSTANTS = 65535
+)
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// line is the source line of the node being compiled.
+	line int
+}
+
+// CompilationScope holds the instructions of the function being compiled.
+// The last two emitted instructions are kept so that a trailing OpPop can be
+// taken back when a block turns out to produce a value.
+type CompilationScope struct {
+	instructions        code.Instructions
+	lines               code.LineTable
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// Bytecode is what the VM runs: the instructions of the main program and the
+// constant pool they refer to. GlobalNames gives the name of each global
+// slot and Lines the source line of each instruction, for error messages
+// and the disassembler.
+type Bytecode struct {
+	Instructions code.Instructions
+	Lines        code.LineTable
+	Constants    []object.Object
+	GlobalNames  []string
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState creates a compiler that keeps adding to an existing symbol
+// table and constant pool, so that globals survive between compilations.
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{{instructions: code.Instructions{}}},
+	}
+}
+
+func (compiler *Compiler) Compile(node abstractSyntaxTree.Node) error {
+	if node != nil {
+		line := compiler.line
+		if line := lineOf(node); line > 0 {
+			compiler.line = line
+		}
+		defer func() { compiler.line = line }()
+	}
+
+	switch node := node.(type) {
+
+	case *abstractSyntaxTree.Program:
+		// Top-level names are declared up front so that a function can call
+		// one defined further down, as it can when the program is evaluated.
+		for _, statement := range node.Statements {
+			if letStatement, ok := statement.(*abstractSyntaxTree.LetStatement); ok {
+				compiler.symbolTable.Define(letStatement.Name.Value)
+			}
+		}
+		for _, statement := range node.Statements {
+			if err := compiler.Compile(statement); err != nil {
+				return err
+			}
+		}
+
+	case *abstractSyntaxTree.ExpressionStatement:
+		if err := compiler.Compile(node.Expression); err != nil {
+			return err
+		}
+		if _, err := compiler.emit(code.OpPop); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.BlockStatement:
+		for _, statement := range node.Statements {
+			if err := compiler.Compile(statement); err != nil {
+				return err
+			}
+		}
+
+	case *abstractSyntaxTree.LetStatement:
+		// A function is bound before its body is compiled so that it can call
+		// itself by name. Any other value is compiled first, so that it still
+		// sees an outer binding of the name it shadows.
+		var symbol Symbol
+		if function, ok := node.Value.(*abstractSyntaxTree.FunctionLiteral); ok {
+			symbol = compiler.symbolTable.Define(node.Name.Value)
+			if err := compiler.compileFunctionLiteral(function, node.Name.Value); err != nil {
+				return err
+			}
+		} else {
+			if err := compiler.Compile(node.Value); err != nil {
+				return err
+			}
+			symbol = compiler.symbolTable.Define(node.Name.Value)
+		}
+		if symbol.Scope == GLOBAL_SCOPE {
+			if _, err := compiler.emit(code.OpSetGlobal, symbol.Index); err != nil {
+				return err
+			}
+		} else if symbol.Index > MAX_LOCALS {
+			return fmt.Errorf("too many local bindings in one function, the limit is %d", MAX_LOCALS)
+		} else if _, err := compiler.emit(code.OpSetLocal, symbol.Index); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.ReturnStatement:
+		if node.ReturnValue == nil {
+			if _, err := compiler.emit(code.OpNull); err != nil {
+				return err
+			}
+		} else if err := compiler.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if _, err := compiler.emit(code.OpReturnValue); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.Identifier:
+		return compiler.loadIdentifier(node)
+
+	case *abstractSyntaxTree.IntegerLiteral:
+		return compiler.emitConstant(&object.Integer{Value: node.Value})
+
+	case *abstractSyntaxTree.FloatLiteral:
+		return compiler.emitConstant(&object.Float{Value: node.Value})
+
+	case *abstractSyntaxTree.StringLiteral:
+		return compiler.emitConstant(&object.String{Value: node.Value})
+
+	case *abstractSyntaxTree.Boolean:
+		op := code.OpFalse
+		if node.Value {
+			op = code.OpTrue
+		}
+		if _, err := compiler.emit(op); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.PrefixEpression:
+		if err := compiler.Compile(node.Rigth); err != nil {
+			return err
+		}
+		var op code.Opcode
+		switch node.Operator {
+		case "!":
+			op = code.OpBang
+		case "-":
+			op = code.OpMinus
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if _, err := compiler.emit(op); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return compiler.compileLogicalExpression(node)
+		}
+		opcode, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		if err := compiler.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := compiler.Compile(node.Right); err != nil {
+			return err
+		}
+		if _, err := compiler.emit(opcode); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.IfExpression:
+		return compiler.compileIfExpression(node)
+
+	case *abstractSyntaxTree.FunctionLiteral:
+		return compiler.compileFunctionLiteral(node, "")
+
+	case *abstractSyntaxTree.CallExpression:
+		if err := compiler.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, argument := range node.Arguments {
+			if err := compiler.Compile(argument); err != nil {
+				return err
+			}
+		}
+		if _, err := compiler.emit(code.OpCall, len(node.Arguments)); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := compiler.Compile(element); err != nil {
+				return err
+			}
+		}
+		if _, err := compiler.emit(code.OpArray, len(node.Elements)); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := compiler.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := compiler.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		if _, err := compiler.emit(code.OpHash, len(node.Pairs)*2); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.IndexExpression:
+		if err := compiler.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := compiler.Compile(node.Index); err != nil {
+			return err
+		}
+		if _, err := compiler.emit(code.OpIndex); err != nil {
+			return err
+		}
+
+	case *abstractSyntaxTree.BadExpression, *abstractSyntaxTree.BadStatement:
+		return fmt.Errorf("cannot evaluate malformed code at %s", node.Pos())
+
+	case nil:
+		return fmt.Errorf("cannot compile a missing expression")
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+// lineOf returns the source line of node. An infix, call or index
+// expression takes the line of its own token rather than that of Pos, which
+// walks down the left operand and would make compiling a long chain
+// quadratic.
+func lineOf(node abstractSyntaxTree.Node) int {
+	switch node := node.(type) {
+	case *abstractSyntaxTree.InfixExpression:
+		return node.Token.Span.Start.Line
+	case *abstractSyntaxTree.CallExpression:
+		return node.Token.Span.Start.Line
+	case *abstractSyntaxTree.IndexExpression:
+		return node.Token.Span.Start.Line
+	}
+	return node.Pos().Line
+}
+
+func (compiler *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: compiler.currentInstructions(),
+		Lines:        compiler.scopes[compiler.scopeIndex].lines,
+		Constants:    compiler.constants,
+		GlobalNames:  compiler.symbolTable.GlobalNames(),
+	}
+}
+
+func (compiler *Compiler) loadIdentifier(identifier *abstractSyntaxTree.Identifier) error {
+	symbol, ok := compiler.symbolTable.Resolve(identifier.Value)
+	if !ok {
+		builtin, isBuiltin := evaluator.LookupBuiltin(identifier.Value)
+		if isBuiltin {
+			index, err := compiler.addConstant(builtin)
+			if err != nil {
+				return err
+			}
+			symbol = compiler.symbolTable.Outermost().DefineBuiltin(index, identifier.Value)
+		} else {
+			// A name nothing defines yet gets a global slot, so that, as in
+			// the evaluator, it is only an error if it is still unbound when
+			// the program reads it.
+			symbol = compiler.symbolTable.Outermost().Define(identifier.Value)
+		}
+	}
+
+	return compiler.loadSymbol(symbol)
+}
+
+func (compiler *Compiler) loadSymbol(symbol Symbol) error {
+	var err error
+	switch symbol.Scope {
+	case GLOBAL_SCOPE:
+		_, err = compiler.emit(code.OpGetGlobal, symbol.Index)
+	case LOCAL_SCOPE:
+		_, err = compiler.emit(code.OpGetLocal, symbol.Index)
+	case BUILTIN_SCOPE:
+		_, err = compiler.emit(code.OpConstant, symbol.Index)
+	case FREE_SCOPE:
+		_, err = compiler.emit(code.OpGetFree, symbol.Index)
+	}
+	return err
+}
+
+// captureSymbol pushes the cell that holds a local or free variable, for a
+// closure to capture.
+func (compiler *Compiler) captureSymbol(symbol Symbol) error {
+	op := code.OpCaptureLocal
+	if symbol.Scope == FREE_SCOPE {
+		op = code.OpCaptureFree
+	}
+
+	_, err := compiler.emit(op, symbol.Index)
+	return err
+}
+
+// compileLogicalExpression lowers && and || to jumps so that the right operand
+// only runs when the left one does not decide the result. Like the
+// evaluator, the result is always a boolean.
+func (compiler *Compiler) compileLogicalExpression(node *abstractSyntaxTree.InfixExpression) error {
+	jump, result, otherwise := code.OpJumpNotTruthy, code.OpTrue, code.OpFalse
+	if node.Operator == "||" {
+		jump, result, otherwise = code.OpJumpTruthy, code.OpFalse, code.OpTrue
+	}
+
+	if err := compiler.Compile(node.Left); err != nil {
+		return err
+	}
+	leftJump, err := compiler.emit(jump, 9999)
+	if err != nil {
+		return err
+	}
+
+	if err := compiler.Compile(node.Right); err != nil {
+		return err
+	}
+	rightJump, err := compiler.emit(jump, 9999)
+	if err != nil {
+		return err
+	}
+
+	if _, err := compiler.emit(result); err != nil {
+		return err
+	}
+	endJump, err := compiler.emit(code.OpJump, 9999)
+	if err != nil {
+		return err
+	}
+
+	if err := compiler.changeOperand(leftJump, len(compiler.currentInstructions())); err != nil {
+		return err
+	}
+	if err := compiler.changeOperand(rightJump, len(compiler.currentInstructions())); err != nil {
+		return err
+	}
+	if _, err := compiler.emit(otherwise); err != nil {
+		return err
+	}
+
+	return compiler.changeOperand(endJump, len(compiler.currentInstructions()))
+}
+
+func (compiler *Compiler) compileIfExpression(node *abstractSyntaxTree.IfExpression) error {
+	if err := compiler.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy, err := compiler.emit(code.OpJumpNotTruthy, 9999)
+	if err != nil {
+		return err
+	}
+
+	if err := compiler.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jump, err := compiler.emit(code.OpJump, 9999)
+	if err != nil {
+		return err
+	}
+	if err := compiler.changeOperand(jumpNotTruthy, len(compiler.currentInstructions())); err != nil {
+		return err
+	}
+
+	if node.Alternative == nil {
+		if _, err := compiler.emit(code.OpNull); err != nil {
+			return err
+		}
+	} else if err := compiler.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	return compiler.changeOperand(jump, len(compiler.currentInstructions()))
+}
+
+// compileBlockValue compiles a block that is used as a value: its last
+// expression stays on the stack, or null when it does not end in one.
+func (compiler *Compiler) compileBlockValue(block *abstractSyntaxTree.BlockStatement) error {
+	if err := compiler.Compile(block); err != nil {
+		return err
+	}
+
+	if compiler.lastInstructionIs(code.OpPop) {
+		compiler.removeLastPop()
+		return nil
+	}
+
+	_, err := compiler.emit(code.OpNull)
+	return err
+}
+
+// compileFunctionLiteral emits an OpClosure that captures every free
+// variable of the function, so that the closure sees later changes to them.
+// name is the let name the function is bound to, if any.
+func (compiler *Compiler) compileFunctionLiteral(node *abstractSyntaxTree.FunctionLiteral, name string) error {
+	compiler.enterScope()
+
+	for _, parameter := range node.Parameters {
+		compiler.symbolTable.Define(parameter.Value)
+	}
+
+	if err := compiler.Compile(node.Body); err != nil {
+		compiler.leaveScope()
+		return err
+	}
+
+	if compiler.lastInstructionIs(code.OpPop) {
+		compiler.replaceLastPopWithReturn()
+	}
+	if !compiler.lastInstructionIs(code.OpReturnValue) {
+		if _, err := compiler.emit(code.OpReturn); err != nil {
+			compiler.leaveScope()
+			return err
+		}
+	}
+
+	freeSymbols := compiler.symbolTable.FreeSymbols
+	numLocals := compiler.symbolTable.numDefinitions
+	instructions, lines := compiler.leaveScope()
+
+	if len(freeSymbols) > MAX_FREE {
+		return fmt.Errorf("too many captured variables in one function, the limit is %d", MAX_FREE)
+	}
+	for _, symbol := range freeSymbols {
+		if err := compiler.captureSymbol(symbol); err != nil {
+			return err
+		}
+	}
+
+	function := &object.CompiledFunction{
+		Instructions:  instructions,
+		Lines:         lines,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          name,
+		Source:        object.FunctionSource(node.Parameters, node.Body),
+	}
+	index, err := compiler.addConstant(function)
+	if err != nil {
+		return err
+	}
+	_, err = compiler.emit(code.OpClosure, index, len(freeSymbols))
+	return err
+}
+
+func (compiler *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(compiler.constants) > MAX_CONSTANTS {
+		return 0, fmt.Errorf("too many constants in one program, the limit is %d", MAX_CONSTANTS)
+	}
+
+	compiler.constants = append(compiler.constants, obj)
+	return len(compiler.constants) - 1, nil
+}
+
+func (compiler *Compiler) emitConstant(obj object.Object) error {
+	index, err := compiler.addConstant(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = compiler.emit(code.OpConstant, index)
+	return err
+}
+
+// emit appends an instruction to the current scope and returns its
+// position. It fails when an operand does not fit in the bytes its
+// definition gives it.
+func (compiler *Compiler) emit(op code.Opcode, operands ...int) (int, error) {
+	if err := checkOperands(op, operands); err != nil {
+		return 0, err
+	}
+
+	instruction := code.Make(op, operands...)
+	position := compiler.addInstruction(instruction)
+
+	compiler.setLastInstruction(op, position)
+
+	return position, nil
+}
+
+func checkOperands(op code.Opcode, operands []int) error {
+	definition, err := code.Lookup(byte(op))
+	if err != nil {
+		return err
+	}
+
+	for index, operand := range operands {
+		limit := 1<<(8*definition.OperandWidths[index]) - 1
+		if operand < 0 || operand > limit {
+			return fmt.Errorf("program too large: %s operand %s=%d is more than the limit of %d",
+				definition.Name, definition.OperandNames[index], operand, limit)
+		}
+	}
+
+	return nil
+}
+
+func (compiler *Compiler) addInstruction(instruction []byte) int {
+	scope := &compiler.scopes[compiler.scopeIndex]
+
+	position := len(scope.instructions)
+	scope.instructions = append(scope.instructions, instruction...)
+	scope.lines = scope.lines.Add(position, compiler.line)
+
+	return position
+}
+
+func (compiler *Compiler) setLastInstruction(op code.Opcode, position int) {
+	previous := compiler.scopes[compiler.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: position}
+
+	compiler.scopes[compiler.scopeIndex].previousInstruction = previous
+	compiler.scopes[compiler.scopeIndex].lastInstruction = last
+}
+
+func (compiler *Compiler) currentInstructions() code.Instructions {
+	return compiler.scopes[compiler.scopeIndex].instructions
+}
+
+func (compiler *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(compiler.currentInstructions()) == 0 {
+		return false
+	}
+	return compiler.scopes[compiler.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (compiler *Compiler) removeLastPop() {
+	last := compiler.scopes[compiler.scopeIndex].lastInstruction
+	previous := compiler.scopes[compiler.scopeIndex].previousInstruction
+
+	compiler.scopes[compiler.scopeIndex].instructions = compiler.currentInstructions()[:last.Position]
+	compiler.scopes[compiler.scopeIndex].lines = compiler.scopes[compiler.scopeIndex].lines.Truncate(last.Position)
+	compiler.scopes[compiler.scopeIndex].lastInstruction = previous
+}
+
+func (compiler *Compiler) replaceLastPopWithReturn() {
+	lastPosition := compiler.scopes[compiler.scopeIndex].lastInstruction.Position
+	compiler.replaceInstruction(lastPosition, code.Make(code.OpReturnValue))
+
+	compiler.scopes[compiler.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (compiler *Compiler) replaceInstruction(position int, newInstruction []byte) {
+	instructions := compiler.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		instructions[position+i] = newInstruction[i]
+	}
+}
+
+func (compiler *Compiler) changeOperand(opPosition int, operand int) error {
+	op := code.Opcode(compiler.currentInstructions()[opPosition])
+	if err := checkOperands(op, []int{operand}); err != nil {
+		return err
+	}
+
+	newInstruction := code.Make(op, operand)
+
+	compiler.replaceInstruction(opPosition, newInstruction)
+	return nil
+}
+
+func (compiler *Compiler) enterScope() {
+	compiler.scopes = append(compiler.scopes, CompilationScope{instructions: code.Instructions{}})
+	compiler.scopeIndex++
+
+	compiler.symbolTable = NewEnclosedSymbolTable(compiler.symbolTable)
+}
+
+func (compiler *Compiler) leaveScope() (code.Instructions, code.LineTable) {
+	instructions := compiler.currentInstructions()
+	lines := compiler.scopes[compiler.scopeIndex].lines
+
+	compiler.scopes = compiler.scopes[:len(compiler.scopes)-1]
+	compiler.scopeIndex--
+
+	compiler.symbolTable = compiler.symbolTable.Outer
+
+	return instructions, lines
+}
@@ -0,0 +1,103 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+// Disassemble writes the instructions of the main program, then those of
+// every function in the constant pool. Each line gives the offset, the
+// source line when it changes, the instruction with named operands and,
+// after a `;`, what a constant or global operand refers to.
+func (bytecode *Bytecode) Disassemble(out io.Writer) error {
+	fmt.Fprintln(out, "== main ==")
+	if err := bytecode.disassembleInstructions(out, bytecode.Instructions, bytecode.Lines); err != nil {
+		return err
+	}
+
+	for index, constant := range bytecode.Constants {
+		function, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(out, "\n== %s (constant %d, parameters=%d, locals=%d) ==\n",
+			Describe(function), index, function.NumParameters, function.NumLocals)
+		if err := bytecode.disassembleInstructions(out, function.Instructions, function.Lines); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (bytecode *Bytecode) disassembleInstructions(out io.Writer, instructions code.Instructions, lines code.LineTable) error {
+	previousLine := -1
+
+	for offset := 0; offset < len(instructions); {
+		definition, err := code.Lookup(instructions[offset])
+		if err != nil {
+			return fmt.Errorf("offset %04d: %w", offset, err)
+		}
+
+		operands, read := code.ReadOperands(definition, instructions[offset+1:])
+
+		line := "   |"
+		if current := lines.Line(offset); current != previousLine {
+			line = fmt.Sprintf("%4d", current)
+			previousLine = current
+		}
+
+		instruction := code.FormatOperands(definition, operands)
+		if comment := bytecode.operandComment(code.Opcode(instructions[offset]), operands); comment != "" {
+			fmt.Fprintf(out, "%04d %s  %-32s ; %s\n", offset, line, instruction, comment)
+		} else {
+			fmt.Fprintf(out, "%04d %s  %s\n", offset, line, instruction)
+		}
+
+		offset += 1 + read
+	}
+
+	return nil
+}
+
+func (bytecode *Bytecode) operandComment(op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant, code.OpClosure:
+		if operands[0] < len(bytecode.Constants) {
+			return Describe(bytecode.Constants[operands[0]])
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if operands[0] < len(bytecode.GlobalNames) {
+			return bytecode.GlobalNames[operands[0]]
+		}
+	}
+
+	return ""
+}
+
+// Describe gives a short, stable description of a value for
+// disassembly and traces: strings are quoted and functions are shown by
+// name rather than by address.
+func Describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return fmt.Sprintf("%q", obj.Value)
+	case *object.CompiledFunction:
+		return strings.TrimSpace("fn " + obj.Name)
+	case *object.Closure:
+		return strings.TrimSpace("closure " + obj.Function.Name)
+	case *object.Builtin:
+		return "builtin " + obj.Name
+	case *object.Cell:
+		return "cell " + Describe(obj.Value)
+	case nil:
+		return "<nil>"
+	default:
+		return obj.Inspect()
+	}
+}
@@ -0,0 +1,117 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GLOBAL_SCOPE  SymbolScope = "GLOBAL"
+	LOCAL_SCOPE   SymbolScope = "LOCAL"
+	BUILTIN_SCOPE SymbolScope = "BUILTIN"
+	FREE_SCOPE    SymbolScope = "FREE"
+)
+
+// Symbol is what the compiler knows about a name: where its value lives and
+// its index there. The index of a builtin is its slot in the constant pool.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable maps the names of one function, or of the program for the
+// outermost table, to symbols. FreeSymbols lists, in the order of their
+// FREE_SCOPE indexes, the enclosing symbols the function captures.
+type SymbolTable struct {
+	Outer       *SymbolTable
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	symbolTable := NewSymbolTable()
+	symbolTable.Outer = outer
+	return symbolTable
+}
+
+// Define returns the symbol for name in this table, allocating a new slot
+// unless name already has one here. A builtin or a captured variable is
+// shadowed by the new slot.
+func (symbolTable *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := symbolTable.store[name]; ok && (symbol.Scope == GLOBAL_SCOPE || symbol.Scope == LOCAL_SCOPE) {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: symbolTable.numDefinitions}
+	if symbolTable.Outer == nil {
+		symbol.Scope = GLOBAL_SCOPE
+	} else {
+		symbol.Scope = LOCAL_SCOPE
+	}
+
+	symbolTable.store[name] = symbol
+	symbolTable.numDefinitions++
+
+	return symbol
+}
+
+// DefineBuiltin records that name refers to the builtin stored at index in
+// the constant pool.
+func (symbolTable *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Scope: BUILTIN_SCOPE, Index: index}
+	symbolTable.store[name] = symbol
+	return symbol
+}
+
+// Resolve looks name up here and then in the enclosing tables. A name that
+// belongs to an enclosing function becomes a free variable of this one, and
+// of every function in between.
+func (symbolTable *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := symbolTable.store[name]
+	if ok || symbolTable.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = symbolTable.Outer.Resolve(name)
+	if !ok || symbol.Scope == GLOBAL_SCOPE || symbol.Scope == BUILTIN_SCOPE {
+		return symbol, ok
+	}
+
+	return symbolTable.defineFree(symbol), true
+}
+
+func (symbolTable *SymbolTable) defineFree(original Symbol) Symbol {
+	symbolTable.FreeSymbols = append(symbolTable.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FREE_SCOPE, Index: len(symbolTable.FreeSymbols) - 1}
+	symbolTable.store[original.Name] = symbol
+
+	return symbol
+}
+
+// Outermost returns the table of the program, where globals and builtins
+// are defined.
+func (symbolTable *SymbolTable) Outermost() *SymbolTable {
+	for symbolTable.Outer != nil {
+		symbolTable = symbolTable.Outer
+	}
+	return symbolTable
+}
+
+// GlobalNames returns the name of every global, indexed by its slot.
+func (symbolTable *SymbolTable) GlobalNames() []string {
+	outermost := symbolTable.Outermost()
+
+	names := make([]string, outermost.numDefinitions)
+	for _, symbol := range outermost.store {
+		if symbol.Scope == GLOBAL_SCOPE {
+			names[symbol.Index] = symbol.Name
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,152 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+		"b": {Name: "b", Scope: GLOBAL_SCOPE, Index: 1},
+		"c": {Name: "c", Scope: LOCAL_SCOPE, Index: 0},
+		"d": {Name: "d", Scope: LOCAL_SCOPE, Index: 1},
+	}
+
+	global := NewSymbolTable()
+
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("redefining a gave a new slot. got=%+v", a)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(3, "len")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	tests := []struct {
+		table    *SymbolTable
+		expected []Symbol
+	}{
+		{
+			global,
+			[]Symbol{
+				{Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+				{Name: "len", Scope: BUILTIN_SCOPE, Index: 3},
+			},
+		},
+		{
+			first,
+			[]Symbol{
+				{Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+				{Name: "len", Scope: BUILTIN_SCOPE, Index: 3},
+				{Name: "b", Scope: LOCAL_SCOPE, Index: 0},
+			},
+		},
+		{
+			second,
+			[]Symbol{
+				{Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+				{Name: "b", Scope: FREE_SCOPE, Index: 0},
+				{Name: "c", Scope: LOCAL_SCOPE, Index: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, symbol := range tt.expected {
+			result, ok := tt.table.Resolve(symbol.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", symbol.Name)
+				continue
+			}
+			if result != symbol {
+				t.Errorf("expected %s to resolve to %+v, got=%+v", symbol.Name, symbol, result)
+			}
+		}
+	}
+
+	if _, ok := second.Resolve("missing"); ok {
+		t.Errorf("missing resolved")
+	}
+
+	expectedFree := []Symbol{{Name: "b", Scope: LOCAL_SCOPE, Index: 0}}
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0] != expectedFree[0] {
+		t.Errorf("free symbols wrong. want=%+v, got=%+v", expectedFree, second.FreeSymbols)
+	}
+}
+
+func TestResolveNestedFree(t *testing.T) {
+	global := NewSymbolTable()
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("a")
+
+	second := NewEnclosedSymbolTable(first)
+	third := NewEnclosedSymbolTable(second)
+
+	symbol, ok := third.Resolve("a")
+	if !ok || symbol != (Symbol{Name: "a", Scope: FREE_SCOPE, Index: 0}) {
+		t.Fatalf("a resolved wrong. got=%+v", symbol)
+	}
+
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0].Scope != LOCAL_SCOPE {
+		t.Errorf("second table should capture a from first. got=%+v", second.FreeSymbols)
+	}
+	if len(third.FreeSymbols) != 1 || third.FreeSymbols[0].Scope != FREE_SCOPE {
+		t.Errorf("third table should capture a from second. got=%+v", third.FreeSymbols)
+	}
+}
+
+func TestDefineShadowsFreeVariable(t *testing.T) {
+	global := NewSymbolTable()
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("x")
+	local := NewEnclosedSymbolTable(outer)
+
+	if symbol, _ := local.Resolve("x"); symbol.Scope != FREE_SCOPE {
+		t.Fatalf("x resolved wrong. got=%+v", symbol)
+	}
+
+	expected := Symbol{Name: "x", Scope: LOCAL_SCOPE, Index: 0}
+	if symbol := local.Define("x"); symbol != expected {
+		t.Errorf("Define did not shadow the free variable. got=%+v", symbol)
+	}
+	if symbol := local.Define("x"); symbol != expected {
+		t.Errorf("Define did not reuse the local slot. got=%+v", symbol)
+	}
+}
+
+func TestGlobalNames(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+	global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+
+	names := local.GlobalNames()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("GlobalNames wrong. got=%v", names)
+	}
+}
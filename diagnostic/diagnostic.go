@@ -0,0 +1,147 @@
+package diagnostic
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+type Severity int
+
+const (
+	ERROR Severity = iota
+	WARNING
+	NOTE
+)
+
+func (severity Severity) String() string {
+	switch severity {
+	case ERROR:
+		return "error"
+	case WARNING:
+		return "warning"
+	case NOTE:
+		return "note"
+	default:
+		return fmt.Sprintf("severity(%d)", int(severity))
+	}
+}
+
+type Code string
+
+const (
+	UNEXPECTED_TOKEN         Code = "E0001"
+	NO_PREFIX_PARSE_FUNCTION Code = "E0002"
+	INVALID_NUMBER           Code = "E0003"
+	UNTERMINATED_BLOCK       Code = "E0004"
+	TOO_MANY_ERRORS          Code = "E0005"
+	UNTERMINATED_STRING      Code = "E0006"
+	INVALID_ESCAPE           Code = "E0007"
+	INVALID_ENCODING         Code = "E0008"
+	UNTERMINATED_COMMENT     Code = "E0009"
+	NUMBER_OUT_OF_RANGE      Code = "E0010"
+	ILLEGAL_CHARACTER        Code = "E0011"
+)
+
+type Fix struct {
+	Message     string
+	Span        token.Span
+	Replacement string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Span     token.Span
+	Message  string
+	Notes    []string
+	Fixes    []Fix
+}
+
+func New(code Code, span token.Span, format string, a ...interface{}) *Diagnostic {
+	return &Diagnostic{Severity: ERROR, Code: code, Span: span, Message: fmt.Sprintf(format, a...)}
+}
+
+func (diagnostic *Diagnostic) WithNote(format string, a ...interface{}) *Diagnostic {
+	diagnostic.Notes = append(diagnostic.Notes, fmt.Sprintf(format, a...))
+	return diagnostic
+}
+
+func (diagnostic *Diagnostic) WithFix(fix Fix) *Diagnostic {
+	diagnostic.Fixes = append(diagnostic.Fixes, fix)
+	return diagnostic
+}
+
+func (diagnostic *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", diagnostic.Span.Start, diagnostic.Severity, diagnostic.Code, diagnostic.Message)
+}
+
+type ErrorList []*Diagnostic
+
+func (errorList *ErrorList) Add(diagnostic *Diagnostic) {
+	*errorList = append(*errorList, diagnostic)
+}
+
+func (errorList ErrorList) Len() int      { return len(errorList) }
+func (errorList ErrorList) Swap(i, j int) { errorList[i], errorList[j] = errorList[j], errorList[i] }
+
+func (errorList ErrorList) Less(i, j int) bool {
+	left := errorList[i].Span.Start
+	right := errorList[j].Span.Start
+
+	if left.Filename != right.Filename {
+		return left.Filename < right.Filename
+	}
+	if left.Line != right.Line {
+		return left.Line < right.Line
+	}
+	if left.Column != right.Column {
+		return left.Column < right.Column
+	}
+	if errorList[i].Severity != errorList[j].Severity {
+		return errorList[i].Severity < errorList[j].Severity
+	}
+	return errorList[i].Message < errorList[j].Message
+}
+
+func (errorList ErrorList) Sort() {
+	sort.Stable(errorList)
+}
+
+// RemoveMultiples sorts the list and keeps only the first diagnostic
+// reported on each line, which is almost always the one that matters.
+func (errorList *ErrorList) RemoveMultiples() {
+	errorList.Sort()
+
+	var last token.Position
+	index := 0
+
+	for _, diagnostic := range *errorList {
+		start := diagnostic.Span.Start
+		if index == 0 || start.Filename != last.Filename || start.Line != last.Line {
+			last = start
+			(*errorList)[index] = diagnostic
+			index++
+		}
+	}
+
+	*errorList = (*errorList)[:index]
+}
+
+func (errorList ErrorList) Error() string {
+	switch len(errorList) {
+	case 0:
+		return "no errors"
+	case 1:
+		return errorList[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", errorList[0].Error(), len(errorList)-1)
+}
+
+func (errorList ErrorList) Err() error {
+	if len(errorList) == 0 {
+		return nil
+	}
+	return errorList
+}
@@ -0,0 +1,97 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+func span(line, column, width int) token.Span {
+	return token.Span{
+		Start: token.Position{Line: line, Column: column},
+		End:   token.Position{Line: line, Column: column + width},
+	}
+}
+
+func TestErrorListSortAndRemoveMultiples(t *testing.T) {
+	errorList := ErrorList{}
+	errorList.Add(New(UNEXPECTED_TOKEN, span(3, 1, 1), "third"))
+	errorList.Add(New(UNEXPECTED_TOKEN, span(1, 5, 1), "second"))
+	errorList.Add(New(UNEXPECTED_TOKEN, span(1, 2, 1), "first"))
+	errorList.Add(New(NO_PREFIX_PARSE_FUNCTION, span(3, 4, 1), "cascade"))
+
+	errorList.Sort()
+
+	expected := []string{"first", "second", "third", "cascade"}
+	for i, message := range expected {
+		if errorList[i].Message != message {
+			t.Errorf("errorList[%d] wrong after Sort. expected=%q, got=%q", i, message, errorList[i].Message)
+		}
+	}
+
+	errorList.RemoveMultiples()
+
+	if len(errorList) != 2 {
+		t.Fatalf("RemoveMultiples kept %d diagnostics, want 2", len(errorList))
+	}
+	if errorList[0].Message != "first" || errorList[1].Message != "third" {
+		t.Errorf("RemoveMultiples kept wrong diagnostics. got=%q, %q", errorList[0].Message, errorList[1].Message)
+	}
+}
+
+func TestErrorListError(t *testing.T) {
+	errorList := ErrorList{}
+
+	if errorList.Err() != nil {
+		t.Errorf("empty list Err() should be nil")
+	}
+
+	errorList.Add(New(UNEXPECTED_TOKEN, span(2, 3, 1), "bad %s", "thing"))
+	if errorList.Error() != "2:3: error[E0001]: bad thing" {
+		t.Errorf("Error() wrong. got=%q", errorList.Error())
+	}
+
+	errorList.Add(New(UNEXPECTED_TOKEN, span(4, 1, 1), "other"))
+	if errorList.Error() != "2:3: error[E0001]: bad thing (and 1 more errors)" {
+		t.Errorf("Error() wrong. got=%q", errorList.Error())
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := "let x = 5;\nlet = 10;"
+	report := New(UNEXPECTED_TOKEN, span(2, 5, 1), "expected next token to be IDENT, got = instead").
+		WithNote("a let statement binds a name").
+		WithFix(Fix{Message: "insert a name", Replacement: "x"})
+
+	var out bytes.Buffer
+	Render(&out, source, report)
+
+	expected := "2:5: error[E0001]: expected next token to be IDENT, got = instead\n" +
+		"  |\n" +
+		"2 | let = 10;\n" +
+		"  |     ^\n" +
+		"  = note: a let statement binds a name\n" +
+		"  = help: insert a name\n"
+
+	if out.String() != expected {
+		t.Errorf("Render wrong.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestRenderUnderlinesSpan(t *testing.T) {
+	source := "\tlet x = 99999999999999999999;"
+	report := New(INVALID_NUMBER, span(1, 10, 20), "integer too large")
+
+	var out bytes.Buffer
+	Render(&out, source, report)
+
+	expected := "1:10: error[E0003]: integer too large\n" +
+		"  |\n" +
+		"1 | \tlet x = 99999999999999999999;\n" +
+		"  | \t        ^^^^^^^^^^^^^^^^^^^^\n"
+
+	if out.String() != expected {
+		t.Errorf("Render wrong.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
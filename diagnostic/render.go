@@ -0,0 +1,70 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Render writes the diagnostic followed by the offending source line with a
+// caret underline beneath the reported span, then any notes and fixes.
+func Render(out io.Writer, source string, diagnostic *Diagnostic) {
+	fmt.Fprintln(out, diagnostic.Error())
+
+	start := diagnostic.Span.Start
+	lines := strings.Split(source, "\n")
+
+	if start.IsValid() && start.Line <= len(lines) {
+		line := strings.TrimRight(lines[start.Line-1], "\r")
+		gutter := strings.Repeat(" ", len(strconv.Itoa(start.Line)))
+
+		fmt.Fprintf(out, "%s |\n", gutter)
+		fmt.Fprintf(out, "%d | %s\n", start.Line, line)
+		fmt.Fprintf(out, "%s | %s%s\n", gutter, indentation(line, start.Column-1), strings.Repeat("^", underlineWidth(diagnostic)))
+	}
+
+	for _, note := range diagnostic.Notes {
+		fmt.Fprintf(out, "  = note: %s\n", note)
+	}
+
+	for _, fix := range diagnostic.Fixes {
+		fmt.Fprintf(out, "  = help: %s\n", fix.Message)
+	}
+}
+
+func RenderAll(out io.Writer, source string, errorList ErrorList) {
+	for _, diagnostic := range errorList {
+		Render(out, source, diagnostic)
+	}
+}
+
+func underlineWidth(diagnostic *Diagnostic) int {
+	start := diagnostic.Span.Start
+	end := diagnostic.Span.End
+
+	if end.Line != start.Line || end.Column <= start.Column {
+		return 1
+	}
+	return end.Column - start.Column
+}
+
+func indentation(line string, width int) string {
+	var out strings.Builder
+
+	for _, char := range line {
+		if width == 0 {
+			break
+		}
+		if char == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+		width--
+	}
+
+	out.WriteString(strings.Repeat(" ", width))
+
+	return out.String()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DIFF_CONTEXT is the number of unchanged lines shown around each change.
+const DIFF_CONTEXT = 3
+
+type edit struct {
+	kind byte // ' ' for a kept line, '-' for a removed one, '+' for an added one
+	line string
+}
+
+// diff returns a unified diff that turns before into after, or "" when they
+// are the same.
+func diff(beforeName, afterName, before, after string) string {
+	if before == after {
+		return ""
+	}
+
+	edits := editScript(splitLines(before), splitLines(after))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", beforeName, afterName)
+
+	// beforeLines[i] and afterLines[i] count the lines of each side that
+	// come before edits[i].
+	beforeLines := make([]int, len(edits)+1)
+	afterLines := make([]int, len(edits)+1)
+	for index, edit := range edits {
+		beforeLines[index+1], afterLines[index+1] = beforeLines[index], afterLines[index]
+		if edit.kind != '+' {
+			beforeLines[index+1]++
+		}
+		if edit.kind != '-' {
+			afterLines[index+1]++
+		}
+	}
+
+	index := 0
+	for {
+		for index < len(edits) && edits[index].kind == ' ' {
+			index++
+		}
+		if index == len(edits) {
+			break
+		}
+
+		start := max(index-DIFF_CONTEXT, 0)
+
+		// Extend the hunk over the next change unless the unchanged lines in
+		// between would be more than the context of both.
+		end := index
+		for end < len(edits) {
+			if edits[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(edits) && edits[next].kind == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*DIFF_CONTEXT {
+				break
+			}
+			end = next
+		}
+		end = min(end+DIFF_CONTEXT, len(edits))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(beforeLines[start], beforeLines[end]), hunkRange(afterLines[start], afterLines[end]))
+		for _, edit := range edits[start:end] {
+			out.WriteByte(edit.kind)
+			out.WriteString(edit.line)
+			if !strings.HasSuffix(edit.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		index = end
+	}
+
+	return out.String()
+}
+
+// hunkRange formats the lines from first up to last as a hunk header does:
+// the first line counting from 1, then the number of lines unless it is 1.
+func hunkRange(first, last int) string {
+	switch last - first {
+	case 0:
+		return fmt.Sprintf("%d,0", first)
+	case 1:
+		return fmt.Sprintf("%d", first+1)
+	default:
+		return fmt.Sprintf("%d,%d", first+1, last-first)
+	}
+}
+
+// splitLines splits text after each newline, keeping the newlines.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// editScript finds a shortest list of edits from before to after with the
+// algorithm of Eugene Myers, "An O(ND) Difference Algorithm and Its
+// Variations".
+func editScript(before, after []string) []edit {
+	offset := len(before) + len(after)
+	frontier := make([]int, 2*offset+2)
+
+	// trace[distance] keeps the frontier as it was before that distance was
+	// searched, for the diagonals from -distance to distance only, since
+	// those are all the backtracking reads.
+	trace := [][]int{}
+
+search:
+	for distance := 0; distance <= offset; distance++ {
+		trace = append(trace, append([]int{}, frontier[offset-distance:offset+distance+1]...))
+
+		for diagonal := -distance; diagonal <= distance; diagonal += 2 {
+			var x int
+			if diagonal == -distance || diagonal != distance && frontier[offset+diagonal-1] < frontier[offset+diagonal+1] {
+				x = frontier[offset+diagonal+1]
+			} else {
+				x = frontier[offset+diagonal-1] + 1
+			}
+			y := x - diagonal
+
+			for x < len(before) && y < len(after) && before[x] == after[y] {
+				x++
+				y++
+			}
+			frontier[offset+diagonal] = x
+
+			if x >= len(before) && y >= len(after) {
+				break search
+			}
+		}
+	}
+
+	edits := []edit{}
+	x, y := len(before), len(after)
+
+	for distance := len(trace) - 1; distance > 0; distance-- {
+		frontier := trace[distance]
+		diagonal := x - y
+
+		var previous int
+		if diagonal == -distance || diagonal != distance && frontier[distance+diagonal-1] < frontier[distance+diagonal+1] {
+			previous = diagonal + 1
+		} else {
+			previous = diagonal - 1
+		}
+		previousX := frontier[distance+previous]
+		previousY := previousX - previous
+
+		for x > previousX && y > previousY {
+			edits = append(edits, edit{' ', before[x-1]})
+			x--
+			y--
+		}
+
+		if x == previousX {
+			edits = append(edits, edit{'+', after[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{'-', before[x-1]})
+			x--
+		}
+	}
+
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{' ', before[x-1]})
+		x--
+		y--
+	}
+
+	for left, right := 0, len(edits)-1; left < right; left, right = left+1, right-1 {
+		edits[left], edits[right] = edits[right], edits[left]
+	}
+
+	return edits
+}
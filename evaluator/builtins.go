@@ -0,0 +1,226 @@
+package evaluator
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+var (
+	builtinsMutex sync.RWMutex
+	builtins      = map[string]*object.Builtin{}
+)
+
+func init() {
+	RegisterBuiltin("len", builtinLen)
+	RegisterBuiltin("first", builtinFirst)
+	RegisterBuiltin("last", builtinLast)
+	RegisterBuiltin("rest", builtinRest)
+	RegisterBuiltin("push", builtinPush)
+}
+
+// RegisterBuiltin makes function callable from scripts under name. Builtins
+// are only consulted when a name is not bound in the environment, so a script
+// can still shadow them with let. Registering a name again replaces the
+// previous function.
+func RegisterBuiltin(name string, function object.BuiltinFunction) {
+	builtinsMutex.Lock()
+	defer builtinsMutex.Unlock()
+
+	builtins[name] = &object.Builtin{Name: name, Function: function}
+}
+
+// LookupBuiltin returns the builtin registered under name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtinsMutex.RLock()
+	defer builtinsMutex.RUnlock()
+
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// CheckArity returns an error unless exactly want arguments were passed.
+func CheckArity(arguments []object.Object, want int) *object.Error {
+	if len(arguments) != want {
+		return newError("wrong number of arguments: want=%d, got=%d", want, len(arguments))
+	}
+	return nil
+}
+
+// The typed argument helpers below return the argument at index converted
+// to its Go value, or an error naming the builtin when it has another type.
+// Call CheckArity first; a missing argument is reported as an arity error.
+
+func IntegerArgument(name string, arguments []object.Object, index int) (int64, *object.Error) {
+	argument, errorObject := argumentAt(arguments, index)
+	if errorObject != nil {
+		return 0, errorObject
+	}
+
+	integer, ok := argument.(*object.Integer)
+	if !ok {
+		return 0, argumentError(name, object.INTEGER_OBJECT, argument)
+	}
+	return integer.Value, nil
+}
+
+// FloatArgument also accepts an integer, widened to a float.
+func FloatArgument(name string, arguments []object.Object, index int) (float64, *object.Error) {
+	argument, errorObject := argumentAt(arguments, index)
+	if errorObject != nil {
+		return 0, errorObject
+	}
+
+	if !isNumber(argument) {
+		return 0, argumentError(name, object.FLOAT_OBJECT, argument)
+	}
+	return toFloat(argument), nil
+}
+
+func StringArgument(name string, arguments []object.Object, index int) (string, *object.Error) {
+	argument, errorObject := argumentAt(arguments, index)
+	if errorObject != nil {
+		return "", errorObject
+	}
+
+	str, ok := argument.(*object.String)
+	if !ok {
+		return "", argumentError(name, object.STRING_OBJECT, argument)
+	}
+	return str.Value, nil
+}
+
+func BooleanArgument(name string, arguments []object.Object, index int) (bool, *object.Error) {
+	argument, errorObject := argumentAt(arguments, index)
+	if errorObject != nil {
+		return false, errorObject
+	}
+
+	boolean, ok := argument.(*object.Boolean)
+	if !ok {
+		return false, argumentError(name, object.BOOLEAN_OBJECT, argument)
+	}
+	return boolean.Value, nil
+}
+
+func ArrayArgument(name string, arguments []object.Object, index int) (*object.Array, *object.Error) {
+	argument, errorObject := argumentAt(arguments, index)
+	if errorObject != nil {
+		return nil, errorObject
+	}
+
+	array, ok := argument.(*object.Array)
+	if !ok {
+		return nil, argumentError(name, object.ARRAY_OBJECT, argument)
+	}
+	return array, nil
+}
+
+func HashArgument(name string, arguments []object.Object, index int) (*object.Hash, *object.Error) {
+	argument, errorObject := argumentAt(arguments, index)
+	if errorObject != nil {
+		return nil, errorObject
+	}
+
+	hash, ok := argument.(*object.Hash)
+	if !ok {
+		return nil, argumentError(name, object.HASH_OBJECT, argument)
+	}
+	return hash, nil
+}
+
+func argumentAt(arguments []object.Object, index int) (object.Object, *object.Error) {
+	if index < 0 || index >= len(arguments) {
+		return nil, newError("wrong number of arguments: want at least %d, got=%d", index+1, len(arguments))
+	}
+	return arguments[index], nil
+}
+
+func argumentError(name string, want object.ObjectType, got object.Object) *object.Error {
+	return newError("argument to `%s` must be %s, got %s", name, want, got.Type())
+}
+
+func builtinLen(arguments ...object.Object) object.Object {
+	if errorObject := CheckArity(arguments, 1); errorObject != nil {
+		return errorObject
+	}
+
+	switch argument := arguments[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(argument.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(argument.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(argument.Pairs))}
+	default:
+		return newError("argument to `len` not supported, got %s", arguments[0].Type())
+	}
+}
+
+func builtinFirst(arguments ...object.Object) object.Object {
+	if errorObject := CheckArity(arguments, 1); errorObject != nil {
+		return errorObject
+	}
+	array, errorObject := ArrayArgument("first", arguments, 0)
+	if errorObject != nil {
+		return errorObject
+	}
+
+	if len(array.Elements) > 0 {
+		return array.Elements[0]
+	}
+	return NULL
+}
+
+func builtinLast(arguments ...object.Object) object.Object {
+	if errorObject := CheckArity(arguments, 1); errorObject != nil {
+		return errorObject
+	}
+	array, errorObject := ArrayArgument("last", arguments, 0)
+	if errorObject != nil {
+		return errorObject
+	}
+
+	if length := len(array.Elements); length > 0 {
+		return array.Elements[length-1]
+	}
+	return NULL
+}
+
+func builtinRest(arguments ...object.Object) object.Object {
+	if errorObject := CheckArity(arguments, 1); errorObject != nil {
+		return errorObject
+	}
+	array, errorObject := ArrayArgument("rest", arguments, 0)
+	if errorObject != nil {
+		return errorObject
+	}
+
+	length := len(array.Elements)
+	if length == 0 {
+		return NULL
+	}
+
+	elements := make([]object.Object, length-1)
+	copy(elements, array.Elements[1:length])
+	return &object.Array{Elements: elements}
+}
+
+func builtinPush(arguments ...object.Object) object.Object {
+	if errorObject := CheckArity(arguments, 2); errorObject != nil {
+		return errorObject
+	}
+	array, errorObject := ArrayArgument("push", arguments, 0)
+	if errorObject != nil {
+		return errorObject
+	}
+
+	length := len(array.Elements)
+
+	elements := make([]object.Object, length+1)
+	copy(elements, array.Elements)
+	elements[length] = arguments[1]
+
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,573 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+// MAX_CALL_DEPTH bounds how deeply function calls can nest. The program
+// itself counts as the first level, as it does for the VM's frames.
+const MAX_CALL_DEPTH = 1 << 14
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+func Eval(node abstractSyntaxTree.Node, environment *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	case *abstractSyntaxTree.Program:
+		return evalProgram(node, environment)
+
+	case *abstractSyntaxTree.ExpressionStatement:
+		return Eval(node.Expression, environment)
+
+	case *abstractSyntaxTree.BlockStatement:
+		return evalBlockStatement(node, environment)
+
+	case *abstractSyntaxTree.LetStatement:
+		value := Eval(node.Value, environment)
+		if isError(value) {
+			return value
+		}
+		environment.Set(node.Name.Value, value)
+
+	case *abstractSyntaxTree.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		value := Eval(node.ReturnValue, environment)
+		if isError(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+
+	case *abstractSyntaxTree.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+
+	case *abstractSyntaxTree.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *abstractSyntaxTree.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *abstractSyntaxTree.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *abstractSyntaxTree.Identifier:
+		return evalIdentifier(node, environment)
+
+	case *abstractSyntaxTree.PrefixEpression:
+		right := Eval(node.Rigth, environment)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+
+	case *abstractSyntaxTree.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, environment)
+		}
+		left := Eval(node.Left, environment)
+		if isError(left) {
+			return left
+		}
+		right := Eval(node.Right, environment)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+
+	case *abstractSyntaxTree.IfExpression:
+		return evalIfExpression(node, environment)
+
+	case *abstractSyntaxTree.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Environment: environment}
+
+	case *abstractSyntaxTree.CallExpression:
+		function := Eval(node.Function, environment)
+		if isError(function) {
+			return function
+		}
+		arguments := evalExpressions(node.Arguments, environment)
+		if len(arguments) == 1 && isError(arguments[0]) {
+			return arguments[0]
+		}
+		return applyFunction(function, arguments, environment.Depth()+1)
+
+	case *abstractSyntaxTree.ArrayLiteral:
+		elements := evalExpressions(node.Elements, environment)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+
+	case *abstractSyntaxTree.HashLiteral:
+		return evalHashLiteral(node, environment)
+
+	case *abstractSyntaxTree.IndexExpression:
+		left := Eval(node.Left, environment)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, environment)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *abstractSyntaxTree.BadExpression, *abstractSyntaxTree.BadStatement:
+		return newError("cannot evaluate malformed code at %s", node.Pos())
+	}
+
+	return nil
+}
+
+// EvalInfix, EvalPrefix, EvalIndex and IsTruthy give the bytecode VM the
+// evaluator's semantics for operators, indexing and conditions, so that both
+// engines agree on every result and error message.
+
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func evalProgram(program *abstractSyntaxTree.Program, environment *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, environment)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalBlockStatement(block *abstractSyntaxTree.BlockStatement, environment *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, environment)
+
+		if result != nil {
+			resultType := result.Type()
+			if resultType == object.RETURN_VALUE_OBJECT || resultType == object.ERROR_OBJECT {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func evalIfExpression(ifExpression *abstractSyntaxTree.IfExpression, environment *object.Environment) object.Object {
+	condition := Eval(ifExpression.Condition, environment)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ifExpression.Consequence, environment)
+	} else if ifExpression.Alternative != nil {
+		return Eval(ifExpression.Alternative, environment)
+	} else {
+		return NULL
+	}
+}
+
+func evalExpressions(expressions []abstractSyntaxTree.Expression, environment *object.Environment) []object.Object {
+	result := []object.Object{}
+
+	for _, expression := range expressions {
+		evaluated := Eval(expression, environment)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// ApplyFunction calls a function or builtin with arguments that have already
+// been evaluated, so that Go code can call back into a script.
+func ApplyFunction(function object.Object, arguments []object.Object) object.Object {
+	return applyFunction(function, arguments, 1)
+}
+
+// applyFunction calls function as the depth-th of the nested calls.
+func applyFunction(function object.Object, arguments []object.Object, depth int) object.Object {
+	switch function := function.(type) {
+	case *object.Function:
+		if len(arguments) != len(function.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(arguments))
+		}
+		// The call stack of the host is the limit otherwise, and running out
+		// of it cannot be recovered from.
+		if depth >= MAX_CALL_DEPTH {
+			return newError("stack overflow: more than %d nested calls", MAX_CALL_DEPTH)
+		}
+		// Every loop in Monkey is a recursive call, so checking here is
+		// enough to stop a cancelled evaluation.
+		if err := function.Environment.Context().Err(); err != nil {
+			return newError("evaluation stopped: %s", err)
+		}
+		extendedEnvironment := extendFunctionEnvironment(function, arguments)
+		extendedEnvironment.SetDepth(depth)
+		evaluated := Eval(function.Body, extendedEnvironment)
+		return unwrapReturnValue(evaluated)
+	case *object.Builtin:
+		if result := function.Function(arguments...); result != nil {
+			return result
+		}
+		return NULL
+	default:
+		return newError("not a function: %s", function.Type())
+	}
+}
+
+func extendFunctionEnvironment(function *object.Function, arguments []object.Object) *object.Environment {
+	environment := object.NewEnclosedEnvironment(function.Environment)
+
+	for index, parameter := range function.Parameters {
+		environment.Set(parameter.Value, arguments[index])
+	}
+
+	return environment
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return obj
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func evalIdentifier(identifier *abstractSyntaxTree.Identifier, environment *object.Environment) object.Object {
+	if value, ok := environment.Get(identifier.Value); ok {
+		return value
+	}
+
+	if builtin, ok := LookupBuiltin(identifier.Value); ok {
+		return builtin
+	}
+
+	return newError("identifier not found: %s", identifier.Value)
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJECT && index.Type() == object.INTEGER_OBJECT:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJECT:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	position := index.(*object.Integer).Value
+
+	if position < 0 || position >= int64(len(elements)) {
+		return NULL
+	}
+
+	return elements[position]
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hash.(*object.Hash).Get(key)
+	if !ok {
+		return NULL
+	}
+
+	return value
+}
+
+func evalHashLiteral(node *abstractSyntaxTree.HashLiteral, environment *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, environment)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, environment)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	switch right {
+	case TRUE:
+		return FALSE
+	case FALSE:
+		return TRUE
+	case NULL:
+		return TRUE
+	default:
+		return FALSE
+	}
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJECT && right.Type() == object.INTEGER_OBJECT:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJECT && right.Type() == object.STRING_OBJECT:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalLogicalExpression only evaluates the right operand when the left one
+// does not already decide the result.
+func evalLogicalExpression(node *abstractSyntaxTree.InfixExpression, environment *object.Environment) object.Object {
+	left := Eval(node.Left, environment)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, environment)
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftValue + rightValue}
+	case "-":
+		return &object.Integer{Value: leftValue - rightValue}
+	case "*":
+		return &object.Integer{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError("division by zero: %d / %d", leftValue, rightValue)
+		}
+		return &object.Integer{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError("division by zero: %d %% %d", leftValue, rightValue)
+		}
+		return &object.Integer{Value: leftValue % rightValue}
+	case "**":
+		if rightValue < 0 {
+			return &object.Float{Value: math.Pow(float64(leftValue), float64(rightValue))}
+		}
+		return &object.Integer{Value: integerPower(leftValue, rightValue)}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression handles arithmetic where at least one side is a
+// float; an integer on the other side is widened to a float first.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		if rightValue == 0 {
+			return newError("division by zero: %s / %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%":
+		if rightValue == 0 {
+			return newError("division by zero: %s %% %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func integerPower(base, exponent int64) int64 {
+	result := int64(1)
+
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+
+	return result
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJECT || obj.Type() == object.FLOAT_OBJECT
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJECT
+	}
+	return false
+}
@@ -0,0 +1,733 @@
+package evaluator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/object"
+	"github.com/Favot/monkey-interpreter/parser"
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 ** 0", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 < 2 == 2 > 1", true},
+		{"1 < 2 != 2 > 1", false},
+		{"true", true},
+		{"false", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 <= 1.5", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"5 && 0", true},
+		{"false && undefined", false},
+		{"true || 1 / 0", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!5", false},
+		{"!!5", true},
+		{"!5 == !6", true},
+		{"!true", false},
+		{"!!false", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + !5", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + !5; 5", "type mismatch: INTEGER + BOOLEAN"},
+		{"-!5", "unknown operator: -BOOLEAN"},
+		{"!5 + !5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; !5 * !5; 5", "unknown operator: BOOLEAN * BOOLEAN"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"5 % 0", "division by zero: 5 % 0"},
+		{"true && undefined", "identifier not found: undefined"},
+		{"true <= false", "unknown operator: BOOLEAN <= BOOLEAN"},
+		{"[1][true]", "index operator not supported: ARRAY[BOOLEAN]"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{"a": 1.5}[1.5]`, "unusable as hash key: FLOAT"},
+		{"-\"a\"", "unknown operator: -STRING"},
+		{"foobar", "identifier not found: foobar"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"5(1)", "not a function: INTEGER"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errorObject, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errorObject.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errorObject.Message)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLetAndReturnStatements(t *testing.T) {
+	five := &abstractSyntaxTree.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5}
+	name := &abstractSyntaxTree.Identifier{Token: token.Token{Type: token.IDENT, Literal: "a"}, Value: "a"}
+
+	program := &abstractSyntaxTree.Program{
+		Statements: []abstractSyntaxTree.Statement{
+			&abstractSyntaxTree.LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: name, Value: five},
+			&abstractSyntaxTree.ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, ReturnValue: name},
+			&abstractSyntaxTree.ExpressionStatement{Token: five.Token, Expression: &abstractSyntaxTree.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "9"}, Value: 9}},
+		},
+	}
+
+	environment := object.NewEnvironment()
+	testIntegerObject(t, Eval(program, environment), 5)
+
+	value, ok := environment.Get("a")
+	if !ok {
+		t.Fatalf("a not bound in environment")
+	}
+	testIntegerObject(t, value, 5)
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	evaluated := testEval("fn(x) { x + 2; };")
+
+	function, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(function.Parameters) != 1 || function.Parameters[0].String() != "x" {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", function.Parameters)
+	}
+
+	if function.Body.String() != "(x + 2)" {
+		t.Fatalf("body is not %q. got=%q", "(x + 2)", function.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+	let newAdder = fn(x) {
+		fn(y) { x + y };
+	};
+
+	let addTwo = newAdder(2);
+	addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestRecursiveFunction(t *testing.T) {
+	input := `
+	let fibonacci = fn(x) {
+		if (x < 2) { return x; }
+		fibonacci(x - 1) + fibonacci(x - 2)
+	};
+	fibonacci(15);`
+
+	testIntegerObject(t, testEval(input), 610)
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello World!"`)
+
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(`let greet = fn(name) { "Hello" + ", " + name + "!" }; greet("Monkey")`)
+
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello, Monkey!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" + "b" == "ab"`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"1e3 - 1", 999},
+		{"7.5 % 2", 1.5},
+		{"2 ** 0.5 ** 2", 1.189207115002721},
+		{"2 ** -1", 0.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("input %s: object is not Float. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("input %s: object has wrong value. got=%g, want=%g", tt.input, result.Value, tt.expected)
+		}
+	}
+}
+
+func TestFloatComparisonAndInspect(t *testing.T) {
+	testBooleanObject(t, testEval("1.5 < 2"), true)
+	testBooleanObject(t, testEval("2 == 2.0"), true)
+	testBooleanObject(t, testEval("0.1 + 0.2 != 0.3"), true)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.0", "3.0"},
+		{"0.25", "0.25"},
+		{"1e21", "1e+21"},
+		{"1.0 / 0.5", "2.0"},
+	}
+
+	for _, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("input %s: Inspect() wrong. expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("input %s: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("café😀")`, 5},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments: want=1, got=2"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			if evaluated != NULL {
+				t.Errorf("input %s: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		case string:
+			errorObject, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("input %s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errorObject.Message != expected {
+				t.Errorf("input %s: wrong error message. expected=%q, got=%q", tt.input, expected, errorObject.Message)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("input %s: obj not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("input %s: wrong num of elements. want=%d, got=%d", tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElement := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElement))
+			}
+		}
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	RegisterBuiltin("testRepeat", func(arguments ...object.Object) object.Object {
+		if errorObject := CheckArity(arguments, 2); errorObject != nil {
+			return errorObject
+		}
+		text, errorObject := StringArgument("testRepeat", arguments, 0)
+		if errorObject != nil {
+			return errorObject
+		}
+		count, errorObject := IntegerArgument("testRepeat", arguments, 1)
+		if errorObject != nil {
+			return errorObject
+		}
+		return &object.String{Value: strings.Repeat(text, int(count))}
+	})
+	RegisterBuiltin("testNothing", func(arguments ...object.Object) object.Object { return nil })
+	t.Cleanup(func() { unregisterBuiltins("testRepeat", "testNothing") })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`testRepeat("ab", 3)`, "ababab"},
+		{`let f = testRepeat; f("x", 2)`, "xx"},
+		{`testRepeat("ab")`, "ERROR: wrong number of arguments: want=2, got=1"},
+		{`testRepeat(1, 3)`, "ERROR: argument to `testRepeat` must be STRING, got INTEGER"},
+		{`testRepeat("ab", "3")`, "ERROR: argument to `testRepeat` must be INTEGER, got STRING"},
+		{`let testRepeat = 5; testRepeat`, "5"},
+		{`testNothing()`, "null"},
+	}
+
+	for _, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("input %s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+// unregisterBuiltins takes test builtins back out of the process-wide
+// registry, so that later tests do not see them.
+func unregisterBuiltins(names ...string) {
+	builtinsMutex.Lock()
+	defer builtinsMutex.Unlock()
+
+	for _, name := range names {
+		delete(builtins, name)
+	}
+}
+
+func TestArgumentHelpers(t *testing.T) {
+	arguments := []object.Object{
+		&object.Integer{Value: 2},
+		&object.Float{Value: 1.5},
+		TRUE,
+		&object.Array{},
+		object.NewHash(),
+	}
+
+	if value, errorObject := FloatArgument("f", arguments, 0); errorObject != nil || value != 2 {
+		t.Errorf("FloatArgument(integer) wrong. got=%v, %v", value, errorObject)
+	}
+	if value, errorObject := FloatArgument("f", arguments, 1); errorObject != nil || value != 1.5 {
+		t.Errorf("FloatArgument(float) wrong. got=%v, %v", value, errorObject)
+	}
+	if value, errorObject := BooleanArgument("f", arguments, 2); errorObject != nil || !value {
+		t.Errorf("BooleanArgument wrong. got=%v, %v", value, errorObject)
+	}
+	if _, errorObject := ArrayArgument("f", arguments, 3); errorObject != nil {
+		t.Errorf("ArrayArgument wrong. got=%v", errorObject)
+	}
+	if _, errorObject := HashArgument("f", arguments, 4); errorObject != nil {
+		t.Errorf("HashArgument wrong. got=%v", errorObject)
+	}
+
+	if _, errorObject := HashArgument("f", arguments, 3); errorObject == nil ||
+		errorObject.Message != "argument to `f` must be HASH, got ARRAY" {
+		t.Errorf("HashArgument(array) wrong. got=%v", errorObject)
+	}
+	if _, errorObject := IntegerArgument("f", arguments, 5); errorObject == nil ||
+		errorObject.Message != "wrong number of arguments: want at least 6, got=5" {
+		t.Errorf("IntegerArgument(missing) wrong. got=%v", errorObject)
+	}
+}
+
+func TestCancelledEnvironment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	environment := object.NewEnvironment()
+	environment.SetContext(ctx)
+
+	program, err := parser.ParseString("let f = fn() { 1 }; let x = 2; f();")
+	if err != nil {
+		t.Fatalf("parse failed: %s", err)
+	}
+
+	evaluated := Eval(program, environment)
+	if evaluated.Inspect() != "ERROR: evaluation stopped: context canceled" {
+		t.Errorf("wrong result. got=%q", evaluated.Inspect())
+	}
+
+	if value, ok := environment.Get("x"); !ok || value.Inspect() != "2" {
+		t.Errorf("statements before the call did not run. got=%v", value)
+	}
+}
+
+func TestMapWithBuiltins(t *testing.T) {
+	input := `
+	let map = fn(arr, f) {
+		let iter = fn(arr, accumulated) {
+			if (len(arr) == 0) {
+				accumulated
+			} else {
+				iter(rest(arr), push(accumulated, f(first(arr))));
+			}
+		};
+		iter(arr, []);
+	};
+	map([1, 2, 3], fn(x) { x * 2 });`
+
+	if actual := testEval(input).Inspect(); actual != "[2, 4, 6]" {
+		t.Errorf("map result wrong. got=%s", actual)
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	if result.Inspect() != "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}" {
+		t.Errorf("result.Inspect() wrong. got=%q", result.Inspect())
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`len({"a": 1, "b": 2})`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("input %s: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestEnclosedEnvironment(t *testing.T) {
+	outer := object.NewEnvironment()
+	outer.Set("x", &object.Integer{Value: 1})
+
+	inner := object.NewEnclosedEnvironment(outer)
+	inner.Set("y", &object.Integer{Value: 2})
+
+	if _, ok := inner.Get("x"); !ok {
+		t.Errorf("inner environment does not see outer binding")
+	}
+	if _, ok := outer.Get("y"); ok {
+		t.Errorf("outer environment sees inner binding")
+	}
+}
+
+func testEval(input string) object.Object {
+	lexer := lexer.NewLexer(input)
+	parser := parser.NewParser(lexer)
+	program := parser.ParseProgram()
+	environment := object.NewEnvironment()
+
+	return Eval(program, environment)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
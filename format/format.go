@@ -0,0 +1,659 @@
+// Package format prints Monkey syntax trees in the one canonical style, so
+// that scripts read the same whoever wrote them.
+//
+// The style is:
+//
+//   - one statement per line, indented by INDENT per block, with at most one
+//     blank line kept between statements that the source separated;
+//   - every statement ends with `;`, except an if expression, which only
+//     needs one when the next statement would otherwise continue it;
+//   - a block that was written on one line with at most one statement stays
+//     on one line, as in `fn(x) { x * 2 }`, and every other block is spread
+//     over several;
+//   - binary operators are surrounded by spaces, and parentheses are only
+//     kept where the parser's precedences need them;
+//   - the elements of an array, hash or call are printed one per line when
+//     the source broke the line after the opening or before the closing
+//     delimiter, or put a comment between them;
+//   - comments stay where they were, either on their own line or at the end
+//     of one.
+//
+// Formatting is idempotent: formatting formatted source gives it back.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/parser"
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+const INDENT = "    "
+
+// ATOM is the precedence of expressions that never need parentheses, such
+// as literals and identifiers.
+const ATOM = parser.INDEX + 1
+
+// Source formats src, read from filename. A script with syntax errors is not
+// formatted: the error is then the parser's diagnostic.ErrorList.
+func Source(filename string, src string) (string, error) {
+	program, err := parser.ParseSource(filename, src)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := Node(&out, program); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// Node writes node in the canonical style. The comments of a program are
+// printed with it; other nodes are printed without comments and without a
+// final newline.
+func Node(out io.Writer, node abstractSyntaxTree.Node) error {
+	printer := &printer{lineStart: true}
+
+	switch node := node.(type) {
+	case *abstractSyntaxTree.Program:
+		printer.comments = node.Comments
+		printer.printStatements(node.Statements, token.Position{})
+		printer.printComments(endOfFile)
+	case abstractSyntaxTree.Statement:
+		printer.printStatement(node)
+	case abstractSyntaxTree.Expression:
+		printer.printExpression(node)
+	case *abstractSyntaxTree.CommentGroup:
+		printer.printDoc(node)
+	default:
+		return fmt.Errorf("format: unexpected node type %T", node)
+	}
+
+	if printer.err != nil {
+		return printer.err
+	}
+
+	_, err := out.Write(printer.out.Bytes())
+	return err
+}
+
+// endOfFile is a position after every comment.
+var endOfFile = token.Position{Offset: math.MaxInt, Line: math.MaxInt}
+
+type printer struct {
+	out       bytes.Buffer
+	indent    int
+	lineStart bool
+
+	// comments holds the comments not printed yet, in source order.
+	comments []*abstractSyntaxTree.CommentGroup
+
+	// line is the source line of what was printed last, or 0 at the start
+	// of a block, where no blank line is kept.
+	line int
+
+	err error
+}
+
+func (printer *printer) write(text string) {
+	if printer.lineStart {
+		printer.out.WriteString(strings.Repeat(INDENT, printer.indent))
+		printer.lineStart = false
+	}
+	printer.out.WriteString(text)
+}
+
+// newline ends the current line, unless nothing was written on it yet.
+func (printer *printer) newline() {
+	if !printer.lineStart {
+		printer.out.WriteByte('\n')
+		printer.lineStart = true
+	}
+}
+
+// separate keeps a blank line before what starts at position when the
+// source had one.
+func (printer *printer) separate(position token.Position) {
+	if printer.line > 0 && position.IsValid() && position.Line > printer.line+1 {
+		printer.newline()
+		printer.out.WriteByte('\n')
+	}
+}
+
+// setLine records that the source up to position was printed.
+func (printer *printer) setLine(position token.Position) {
+	if position.IsValid() && position.Line > printer.line {
+		printer.line = position.Line
+	}
+}
+
+func (printer *printer) fail(position token.Position, format string, arguments ...any) {
+	if printer.err == nil {
+		printer.err = fmt.Errorf("%s: "+format, append([]any{position}, arguments...)...)
+	}
+}
+
+// printComments prints, each on its own line, the comments that come
+// before position.
+func (printer *printer) printComments(before token.Position) {
+	if !before.IsValid() {
+		return
+	}
+
+	for len(printer.comments) > 0 && printer.comments[0].Pos().Offset < before.Offset {
+		group := printer.comments[0]
+		printer.comments = printer.comments[1:]
+
+		for _, comment := range group.List {
+			printer.separate(comment.Span.Start)
+			printer.write(comment.Text)
+			printer.newline()
+			printer.line = comment.Span.End.Line
+		}
+	}
+}
+
+// printTrailingComments prints at the end of the current line the comments
+// that come before after, which had no better place, and those on the same
+// line as after that come before the next thing to print.
+func (printer *printer) printTrailingComments(after, before token.Position) {
+	if !after.IsValid() {
+		return
+	}
+
+	for len(printer.comments) > 0 {
+		start := printer.comments[0].Pos()
+
+		inside := start.Offset < after.Offset
+		sameLine := start.Line == after.Line && (!before.IsValid() || start.Offset < before.Offset)
+		if !inside && !sameLine {
+			return
+		}
+
+		for _, comment := range printer.comments[0].List {
+			if !printer.lineStart {
+				printer.write(" ")
+			}
+			printer.write(comment.Text)
+			if comment.Kind != token.BLOCK_COMMENT {
+				printer.newline()
+			}
+			printer.setLine(comment.Span.End)
+		}
+
+		printer.comments = printer.comments[1:]
+	}
+}
+
+// hasComments reports whether a comment not printed yet lies between from
+// and to.
+func (printer *printer) hasComments(from, to token.Position) bool {
+	if !from.IsValid() || !to.IsValid() {
+		return false
+	}
+
+	for _, group := range printer.comments {
+		offset := group.Pos().Offset
+		if offset >= to.Offset {
+			return false
+		}
+		if offset >= from.Offset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printDoc prints a doc comment that has no position in the source, such
+// as one built by a tool, above what it documents.
+func (printer *printer) printDoc(doc *abstractSyntaxTree.CommentGroup) {
+	for _, comment := range doc.List {
+		printer.write(comment.Text)
+		printer.newline()
+	}
+}
+
+// printStatements prints statements one per line, then the comments that
+// come before end, the closing brace of their block.
+func (printer *printer) printStatements(statements []abstractSyntaxTree.Statement, end token.Position) {
+	for index, statement := range statements {
+		printer.printComments(statement.Pos())
+		printer.separate(statement.Pos())
+
+		printer.printStatement(statement)
+
+		var next abstractSyntaxTree.Statement
+		before := end
+		if index+1 < len(statements) {
+			next = statements[index+1]
+			before = next.Pos()
+		}
+
+		if needsSemicolon(statement, next) {
+			printer.write(";")
+		}
+
+		printer.setLine(statement.End())
+		printer.printTrailingComments(statement.End(), before)
+		printer.newline()
+	}
+
+	printer.printComments(end)
+}
+
+// needsSemicolon reports whether statement must end with `;` when next
+// follows it. An if expression reads as a statement of its own, unless the
+// next statement starts with a token that would continue it, as in
+// `if (x) { a } -1`.
+func needsSemicolon(statement, next abstractSyntaxTree.Statement) bool {
+	switch statement := statement.(type) {
+	case *abstractSyntaxTree.BlockStatement:
+		return false
+	case *abstractSyntaxTree.ExpressionStatement:
+		if _, ok := statement.Expression.(*abstractSyntaxTree.IfExpression); !ok {
+			return true
+		}
+	default:
+		return true
+	}
+
+	following, ok := next.(*abstractSyntaxTree.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	switch leadingToken(following.Expression) {
+	case token.LEFT_PARENTHESIS, token.LEFT_BRACKET, token.MINUS:
+		return true
+	}
+	return false
+}
+
+// leadingToken returns the first token expression is printed with, for the
+// tokens that matter to needsSemicolon.
+func leadingToken(expression abstractSyntaxTree.Expression) token.TokenType {
+	switch expression := expression.(type) {
+	case *abstractSyntaxTree.InfixExpression:
+		if needsLeftParentheses(expression, expression.Left) {
+			return token.LEFT_PARENTHESIS
+		}
+		return leadingToken(expression.Left)
+	case *abstractSyntaxTree.CallExpression:
+		if precedence(expression.Function) < parser.CALL {
+			return token.LEFT_PARENTHESIS
+		}
+		return leadingToken(expression.Function)
+	case *abstractSyntaxTree.IndexExpression:
+		if precedence(expression.Left) < parser.CALL {
+			return token.LEFT_PARENTHESIS
+		}
+		return leadingToken(expression.Left)
+	case *abstractSyntaxTree.PrefixEpression:
+		return token.TokenType(expression.Operator)
+	case *abstractSyntaxTree.IntegerLiteral, *abstractSyntaxTree.FloatLiteral:
+		if isNegative(expression) {
+			return token.MINUS
+		}
+	case *abstractSyntaxTree.ArrayLiteral:
+		return token.LEFT_BRACKET
+	}
+	return token.ILLEGAL
+}
+
+func (printer *printer) printStatement(statement abstractSyntaxTree.Statement) {
+	switch statement := statement.(type) {
+	case *abstractSyntaxTree.LetStatement:
+		if statement.Doc != nil && !statement.Doc.Pos().IsValid() {
+			printer.printDoc(statement.Doc)
+		}
+
+		printer.write("let ")
+		if statement.Name == nil {
+			printer.fail(statement.Pos(), "let statement without a name")
+			return
+		}
+		printer.write(statement.Name.Value)
+		printer.write(" = ")
+		printer.printExpression(statement.Value)
+
+	case *abstractSyntaxTree.ReturnStatement:
+		printer.write("return")
+		if statement.ReturnValue != nil {
+			printer.write(" ")
+			printer.printExpression(statement.ReturnValue)
+		}
+
+	case *abstractSyntaxTree.ExpressionStatement:
+		printer.printExpression(statement.Expression)
+
+	case *abstractSyntaxTree.BlockStatement:
+		printer.printBlock(statement)
+
+	case *abstractSyntaxTree.BadStatement:
+		printer.fail(statement.Pos(), "cannot format a statement with syntax errors")
+
+	default:
+		printer.fail(token.Position{}, "unexpected statement type %T", statement)
+	}
+}
+
+// printBlock prints a block on one line when it was written on one line and
+// holds at most one statement, and over several lines otherwise.
+func (printer *printer) printBlock(block *abstractSyntaxTree.BlockStatement) {
+	open, end := block.Token.Span, block.RightBrace.Span
+
+	if !printer.hasComments(open.End, end.Start) {
+		switch {
+		case len(block.Statements) == 0:
+			printer.write("{}")
+			return
+		case len(block.Statements) == 1 && open.Start.IsValid() && open.Start.Line == end.Start.Line:
+			statement := block.Statements[0]
+
+			printer.write("{ ")
+			printer.printStatement(statement)
+			if returnStatement, ok := statement.(*abstractSyntaxTree.ReturnStatement); ok && returnStatement.ReturnValue == nil {
+				printer.write(";")
+			}
+			printer.write(" }")
+			return
+		}
+	}
+
+	before := end.Start
+	if len(block.Statements) > 0 {
+		before = block.Statements[0].Pos()
+	}
+
+	printer.write("{")
+	printer.printTrailingComments(open.End, before)
+	printer.newline()
+
+	printer.indent++
+	printer.line = 0
+	printer.printStatements(block.Statements, end.Start)
+	printer.indent--
+
+	printer.write("}")
+}
+
+func (printer *printer) printExpression(expression abstractSyntaxTree.Expression) {
+	switch expression := expression.(type) {
+	case nil:
+		printer.fail(token.Position{}, "missing expression")
+
+	case *abstractSyntaxTree.Identifier:
+		printer.write(expression.Value)
+
+	case *abstractSyntaxTree.IntegerLiteral:
+		printer.write(integerText(expression))
+
+	case *abstractSyntaxTree.FloatLiteral:
+		if math.IsInf(expression.Value, 0) || math.IsNaN(expression.Value) {
+			printer.fail(expression.Pos(), "float %v has no literal", expression.Value)
+			return
+		}
+		printer.write(floatText(expression))
+
+	case *abstractSyntaxTree.StringLiteral:
+		printer.write(abstractSyntaxTree.Quote(expression.Value))
+
+	case *abstractSyntaxTree.Boolean:
+		printer.write(strconv.FormatBool(expression.Value))
+
+	case *abstractSyntaxTree.PrefixEpression:
+		printer.write(expression.Operator)
+		printer.printOperand(expression.Rigth, precedence(expression.Rigth) < parser.PREFIX)
+
+	case *abstractSyntaxTree.InfixExpression:
+		printer.printOperand(expression.Left, needsLeftParentheses(expression, expression.Left))
+		printer.write(" " + expression.Operator + " ")
+		printer.printOperand(expression.Right, needsRightParentheses(expression, expression.Right))
+
+	case *abstractSyntaxTree.CallExpression:
+		printer.printOperand(expression.Function, precedence(expression.Function) < parser.CALL)
+
+		elements := []element{}
+		for _, argument := range expression.Arguments {
+			elements = append(elements, printer.expressionElement(argument))
+		}
+		printer.printList("(", ")", expression.Token, expression.RightParenthesis, elements)
+
+	case *abstractSyntaxTree.IndexExpression:
+		printer.printOperand(expression.Left, precedence(expression.Left) < parser.CALL)
+		printer.write("[")
+		printer.printExpression(expression.Index)
+		printer.write("]")
+
+	case *abstractSyntaxTree.ArrayLiteral:
+		elements := []element{}
+		for _, value := range expression.Elements {
+			elements = append(elements, printer.expressionElement(value))
+		}
+		printer.printList("[", "]", expression.Token, expression.RightBracket, elements)
+
+	case *abstractSyntaxTree.HashLiteral:
+		elements := []element{}
+		for _, pair := range expression.Pairs {
+			pair := pair
+			if pair.Key == nil || pair.Value == nil {
+				printer.fail(expression.Pos(), "hash pair without a key or a value")
+				return
+			}
+			elements = append(elements, element{pair.Key.Pos(), pair.Value.End(), func() {
+				printer.printExpression(pair.Key)
+				printer.write(": ")
+				printer.printExpression(pair.Value)
+			}})
+		}
+		printer.printList("{", "}", expression.Token, expression.RightBrace, elements)
+
+	case *abstractSyntaxTree.FunctionLiteral:
+		parameters := []string{}
+		for _, parameter := range expression.Parameters {
+			parameters = append(parameters, parameter.Value)
+		}
+		printer.write("fn(" + strings.Join(parameters, ", ") + ") ")
+		printer.printBody(expression.Pos(), expression.Body)
+
+	case *abstractSyntaxTree.IfExpression:
+		printer.write("if (")
+		printer.printExpression(expression.Condition)
+		printer.write(") ")
+		printer.printBody(expression.Pos(), expression.Consequence)
+		if expression.Alternative != nil {
+			printer.write(" else ")
+			printer.printBlock(expression.Alternative)
+		}
+
+	case *abstractSyntaxTree.BadExpression:
+		printer.fail(expression.Pos(), "cannot format an expression with syntax errors")
+
+	default:
+		printer.fail(token.Position{}, "unexpected expression type %T", expression)
+	}
+}
+
+func (printer *printer) printBody(position token.Position, body *abstractSyntaxTree.BlockStatement) {
+	if body == nil {
+		printer.fail(position, "missing block")
+		return
+	}
+	printer.printBlock(body)
+}
+
+func (printer *printer) printOperand(operand abstractSyntaxTree.Expression, parentheses bool) {
+	if parentheses {
+		printer.write("(")
+	}
+	printer.printExpression(operand)
+	if parentheses {
+		printer.write(")")
+	}
+}
+
+// precedence returns how tightly expression holds together, as the
+// precedence of the operator it was parsed with. A negative literal, which
+// only a tool can build, is printed with a minus and so holds together like
+// a prefix expression.
+func precedence(expression abstractSyntaxTree.Expression) int {
+	switch expression := expression.(type) {
+	case *abstractSyntaxTree.InfixExpression:
+		return parser.Precedence(token.TokenType(expression.Operator))
+	case *abstractSyntaxTree.PrefixEpression:
+		return parser.PREFIX
+	case *abstractSyntaxTree.CallExpression:
+		return parser.CALL
+	case *abstractSyntaxTree.IndexExpression:
+		return parser.INDEX
+	}
+	if isNegative(expression) {
+		return parser.PREFIX
+	}
+	return ATOM
+}
+
+func isNegative(expression abstractSyntaxTree.Expression) bool {
+	switch expression := expression.(type) {
+	case *abstractSyntaxTree.IntegerLiteral:
+		return expression.Value < 0
+	case *abstractSyntaxTree.FloatLiteral:
+		return math.Signbit(expression.Value)
+	}
+	return false
+}
+
+func needsLeftParentheses(infix *abstractSyntaxTree.InfixExpression, left abstractSyntaxTree.Expression) bool {
+	operator := token.TokenType(infix.Operator)
+	return precedence(left) < parser.Precedence(operator) ||
+		precedence(left) == parser.Precedence(operator) && parser.IsRightAssociative(operator)
+}
+
+// needsRightParentheses is the mirror of needsLeftParentheses, except that
+// a prefix expression or a negative literal never needs them: the parser
+// applies a prefix operator wherever an operand starts, as in `2 ** -1`.
+func needsRightParentheses(infix *abstractSyntaxTree.InfixExpression, right abstractSyntaxTree.Expression) bool {
+	if _, ok := right.(*abstractSyntaxTree.PrefixEpression); ok || isNegative(right) {
+		return false
+	}
+
+	operator := token.TokenType(infix.Operator)
+	return precedence(right) < parser.Precedence(operator) ||
+		precedence(right) == parser.Precedence(operator) && !parser.IsRightAssociative(operator)
+}
+
+// integerText returns the literal as written, unless it was built without
+// one or with one that no longer matches its value.
+func integerText(literal *abstractSyntaxTree.IntegerLiteral) string {
+	digits, base := lexer.IntegerDigits(literal.Token.Literal)
+	if value, err := strconv.ParseInt(digits, base, 64); err == nil && value == literal.Value {
+		return literal.Token.Literal
+	}
+	return strconv.FormatInt(literal.Value, 10)
+}
+
+// floatText is integerText for floats. The text always has a `.` or an
+// exponent, or it would be read back as an integer.
+func floatText(literal *abstractSyntaxTree.FloatLiteral) string {
+	written := literal.Token.Literal
+	if value, err := strconv.ParseFloat(strings.ReplaceAll(written, "_", ""), 64); err == nil && value == literal.Value && strings.ContainsAny(written, ".eE") {
+		return written
+	}
+
+	text := strconv.FormatFloat(literal.Value, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".e") {
+		text += ".0"
+	}
+	return text
+}
+
+// element is an item of a comma separated list: an argument, an array
+// element or a hash pair.
+type element struct {
+	pos, end token.Position
+	print    func()
+}
+
+func (printer *printer) expressionElement(expression abstractSyntaxTree.Expression) element {
+	if expression == nil {
+		return element{print: func() { printer.printExpression(nil) }}
+	}
+	return element{expression.Pos(), expression.End(), func() { printer.printExpression(expression) }}
+}
+
+// printList prints elements between open and close, on one line unless the
+// source broke the line inside the delimiters or put comments between the
+// elements.
+func (printer *printer) printList(open, close string, opening, closing token.Token, elements []element) {
+	printer.write(open)
+
+	if !printer.spreadList(opening, closing, elements) {
+		for index, element := range elements {
+			if index > 0 {
+				printer.write(", ")
+			}
+			element.print()
+		}
+		printer.write(close)
+		return
+	}
+
+	printer.printTrailingComments(opening.Span.End, elements[0].pos)
+	printer.newline()
+
+	printer.indent++
+	printer.line = 0
+
+	for index, element := range elements {
+		printer.printComments(element.pos)
+		printer.separate(element.pos)
+
+		element.print()
+
+		before := closing.Span.Start
+		if index+1 < len(elements) {
+			printer.write(",")
+			before = elements[index+1].pos
+		}
+
+		printer.setLine(element.end)
+		printer.printTrailingComments(element.end, before)
+		printer.newline()
+	}
+
+	printer.printComments(closing.Span.Start)
+	printer.indent--
+
+	printer.write(close)
+}
+
+func (printer *printer) spreadList(opening, closing token.Token, elements []element) bool {
+	if len(elements) == 0 {
+		return false
+	}
+
+	first, last := elements[0], elements[len(elements)-1]
+	if first.pos.IsValid() && first.pos.Line > opening.Span.End.Line && opening.Span.End.IsValid() ||
+		closing.Span.Start.IsValid() && closing.Span.Start.Line > last.end.Line && last.end.IsValid() {
+		return true
+	}
+
+	previous := opening.Span.End
+	for _, element := range elements {
+		if printer.hasComments(previous, element.pos) {
+			return true
+		}
+		previous = element.end
+	}
+	return printer.hasComments(previous, closing.Span.Start)
+}
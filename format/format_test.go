@@ -0,0 +1,271 @@
+package format
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/parser"
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let   x=5", "let x = 5;\n"},
+		{"let x = 5; let y = 6;", "let x = 5;\nlet y = 6;\n"},
+		{"return", "return;\n"},
+		{"return x", "return x;\n"},
+		{"", ""},
+		{
+			"let x = 1;\n\n\n\nlet y = 2;\nlet z = 3;",
+			"let x = 1;\n\nlet y = 2;\nlet z = 3;\n",
+		},
+		{"-a * b", "-a * b;\n"},
+		{"(-a) * b", "-a * b;\n"},
+		{"-(a * b)", "-(a * b);\n"},
+		{"!(a == b)", "!(a == b);\n"},
+		{"a + (b * c)", "a + b * c;\n"},
+		{"(a + b) * c", "(a + b) * c;\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"2 ** (3 ** 2)", "2 ** 3 ** 2;\n"},
+		{"(2 ** 3) ** 2", "(2 ** 3) ** 2;\n"},
+		{"-(2 ** 2)", "-2 ** 2;\n"},
+		{"(-2) ** 2", "(-2) ** 2;\n"},
+		{"2 ** (-1)", "2 ** -1;\n"},
+		{"a - (-b)", "a - -b;\n"},
+		{"- (-a)", "--a;\n"},
+		{"(a || b) && c", "(a || b) && c;\n"},
+		{"a || (b && c)", "a || b && c;\n"},
+		{"(a < b) == (c > d)", "a < b == c > d;\n"},
+		{"(f)(1)", "f(1);\n"},
+		{"(f(1))(2)", "f(1)(2);\n"},
+		{"(a + b)(1)", "(a + b)(1);\n"},
+		{"(a[0])[1]", "a[0][1];\n"},
+		{"(-a)[0]", "(-a)[0];\n"},
+		{"-(a[0])", "-a[0];\n"},
+		{"f()[0]", "f()[0];\n"},
+		{"fn(x){x}(5)", "fn(x) { x }(5);\n"},
+		{`"tab	and \u{41}"`, "\"tab\\tand A\";\n"},
+		{"1_000 + 0x1F + 1.50 + 1e3", "1_000 + 0x1F + 1.50 + 1e3;\n"},
+		{"[1,2,  3] ; {\"a\":1,true:[ ]}; {}", "[1, 2, 3];\n{\"a\": 1, true: []};\n{};\n"},
+		{"f( a,b )", "f(a, b);\n"},
+		{"let add = fn(a,b){a+b};", "let add = fn(a, b) { a + b };\n"},
+		{"let f = fn() {};", "let f = fn() {};\n"},
+		{"let f = fn() {\n};", "let f = fn() {};\n"},
+		{"let f = fn() { return; };", "let f = fn() { return; };\n"},
+		{
+			"let f = fn(x) { let y = x * 2; y };",
+			"let f = fn(x) {\n    let y = x * 2;\n    y;\n};\n",
+		},
+		{
+			"let f = fn(x) {\nx * 2\n};",
+			"let f = fn(x) {\n    x * 2;\n};\n",
+		},
+		{
+			"if (a) { 1 } else { 2 }",
+			"if (a) { 1 } else { 2 }\n",
+		},
+		{
+			"if (a) { b } if (c) { d } let e = 1;",
+			"if (a) { b }\nif (c) { d }\nlet e = 1;\n",
+		},
+		{
+			"if (a) { b }; -1",
+			"if (a) { b };\n-1;\n",
+		},
+		{
+			"if (a) { b }; [1][0]",
+			"if (a) { b };\n[1][0];\n",
+		},
+		{
+			"if (a) { b }; (c + d) * e",
+			"if (a) { b };\n(c + d) * e;\n",
+		},
+		{
+			"if (a) { b }; !c",
+			"if (a) { b }\n!c;\n",
+		},
+		{
+			"if (a) {\n  if (b) { c } else {\n    d\n  }\n}",
+			"if (a) {\n    if (b) { c } else {\n        d;\n    }\n}\n",
+		},
+		{
+			"map(arr, fn(x) {\n  x * 2\n})",
+			"map(arr, fn(x) {\n    x * 2;\n});\n",
+		},
+		{
+			"let h = {\n\"a\": 1,\n\"b\": [1,\n2]}",
+			"let h = {\n    \"a\": 1,\n    \"b\": [1, 2]\n};\n",
+		},
+		{
+			"puts(\n  1,\n\n  2\n)",
+			"puts(\n    1,\n\n    2\n);\n",
+		},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"#!/usr/bin/env monkey\nputs(1)",
+			"#!/usr/bin/env monkey\nputs(1);\n",
+		},
+		{
+			"// The answer.\nlet x=42 // trailing\n\n// the end",
+			"// The answer.\nlet x = 42; // trailing\n\n// the end\n",
+		},
+		{
+			"let a = 1; let b = 2; // two",
+			"let a = 1;\nlet b = 2; // two\n",
+		},
+		{
+			"/* one */ /* two */ let a = 1; /* three */ /* four */",
+			"/* one */\n/* two */\nlet a = 1; /* three */ /* four */\n",
+		},
+		{
+			"let a = 1 + /* inside */ 2;",
+			"let a = 1 + 2; /* inside */\n",
+		},
+		{
+			"let a = 1 +\n  // one\n  // two\n  2;",
+			"let a = 1 + 2; // one\n// two\n",
+		},
+		{
+			"let f = fn(x) { // why\n  // first\n\n  x // value\n  // last\n};",
+			"let f = fn(x) { // why\n    // first\n\n    x; // value\n    // last\n};\n",
+		},
+		{
+			"let f = fn() { /* todo */ };",
+			"let f = fn() { /* todo */\n};\n",
+		},
+		{
+			"if (a) {\n  1\n} // after\nelse { 2 }",
+			"if (a) {\n    1;\n} else { 2 } // after\n",
+		},
+		{
+			"let b = [1, /* one */ 2];",
+			"let b = [\n    1, /* one */\n    2\n];\n",
+		},
+		{
+			"let h = {\n  // first\n  \"a\": 1, // one\n  \"b\": 2\n  // last\n};",
+			"let h = {\n    // first\n    \"a\": 1, // one\n    \"b\": 2\n    // last\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		testFormat(t, tt.input, tt.expected)
+	}
+}
+
+// testFormat checks that input formats to expected, that expected is
+// already formatted, and that formatting did not change what the program
+// means.
+func testFormat(t *testing.T, input, expected string) {
+	t.Helper()
+
+	actual, err := Source("", input)
+	if err != nil {
+		t.Errorf("Source(%q) failed: %s", input, err)
+		return
+	}
+	if actual != expected {
+		t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=     %q", input, expected, actual)
+		return
+	}
+
+	again, err := Source("", actual)
+	if err != nil || again != actual {
+		t.Errorf("formatting %q again wrong. got=%q (%v)", actual, again, err)
+	}
+
+	before, _ := parser.ParseString(input)
+	after, _ := parser.ParseString(actual)
+	if before.String() != after.String() {
+		t.Errorf("formatting %q changed the program.\nbefore=%q\nafter= %q", input, before.String(), after.String())
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source("broken.monkey", "let x = ;")
+	if err == nil || err.Error() != "broken.monkey:1:9: error[E0002]: expected an expression, got `;`" {
+		t.Errorf("error wrong. got=%v", err)
+	}
+}
+
+func TestNode(t *testing.T) {
+	one := &abstractSyntaxTree.IntegerLiteral{Value: 1}
+	two := &abstractSyntaxTree.IntegerLiteral{Token: token.Token{Literal: "0x2"}, Value: 3}
+	sum := &abstractSyntaxTree.InfixExpression{Left: one, Operator: "+", Right: two}
+
+	tests := []struct {
+		node     abstractSyntaxTree.Node
+		expected string
+	}{
+		{sum, "1 + 3"},
+		{&abstractSyntaxTree.InfixExpression{Left: sum, Operator: "*", Right: one}, "(1 + 3) * 1"},
+		{&abstractSyntaxTree.FloatLiteral{Value: 2}, "2.0"},
+		{&abstractSyntaxTree.FloatLiteral{Value: 1e100}, "1e+100"},
+		{&abstractSyntaxTree.FloatLiteral{Token: token.Token{Literal: "1"}, Value: 1}, "1.0"},
+		{&abstractSyntaxTree.InfixExpression{Left: &abstractSyntaxTree.FloatLiteral{Value: 1}, Operator: "/", Right: two}, "1.0 / 3"},
+		{&abstractSyntaxTree.InfixExpression{Left: &abstractSyntaxTree.IntegerLiteral{Value: -2}, Operator: "**", Right: two}, "(-2) ** 3"},
+		{&abstractSyntaxTree.InfixExpression{Left: two, Operator: "**", Right: &abstractSyntaxTree.FloatLiteral{Value: -0.5}}, "3 ** -0.5"},
+		{&abstractSyntaxTree.IndexExpression{Left: &abstractSyntaxTree.IntegerLiteral{Value: -1}, Index: one}, "(-1)[1]"},
+		{&abstractSyntaxTree.StringLiteral{Value: "a\"b"}, `"a\"b"`},
+		{
+			&abstractSyntaxTree.Program{Statements: []abstractSyntaxTree.Statement{
+				&abstractSyntaxTree.LetStatement{
+					Doc:   &abstractSyntaxTree.CommentGroup{List: []token.Trivia{{Kind: token.LINE_COMMENT, Text: "// Doc."}}},
+					Name:  &abstractSyntaxTree.Identifier{Value: "x"},
+					Value: sum,
+				},
+				&abstractSyntaxTree.ExpressionStatement{Expression: &abstractSyntaxTree.FunctionLiteral{
+					Body: &abstractSyntaxTree.BlockStatement{Statements: []abstractSyntaxTree.Statement{
+						&abstractSyntaxTree.ReturnStatement{ReturnValue: one},
+					}},
+				}},
+			}},
+			"// Doc.\nlet x = 1 + 3;\nfn() {\n    return 1;\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := Node(&out, tt.node); err != nil {
+			t.Errorf("Node(%s) failed: %s", tt.node.String(), err)
+			continue
+		}
+		if out.String() != tt.expected {
+			t.Errorf("Node(%s) wrong. expected=%q, got=%q", tt.node.String(), tt.expected, out.String())
+		}
+	}
+}
+
+func TestNodeErrors(t *testing.T) {
+	tests := []struct {
+		node     abstractSyntaxTree.Node
+		expected string
+	}{
+		{&abstractSyntaxTree.InfixExpression{Operator: "+"}, "-: missing expression"},
+		{&abstractSyntaxTree.FloatLiteral{Value: math.Inf(1)}, "-: float +Inf has no literal"},
+		{&abstractSyntaxTree.BadStatement{From: token.Position{Line: 2, Column: 3}}, "2:3: cannot format a statement with syntax errors"},
+	}
+
+	for _, tt := range tests {
+		err := Node(&bytes.Buffer{}, tt.node)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("error wrong. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+}
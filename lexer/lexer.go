@@ -1,43 +1,108 @@
 package lexer
 
-import "github.com/Favot/monkey-interpreter/token"
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Favot/monkey-interpreter/diagnostic"
+	"github.com/Favot/monkey-interpreter/token"
+	"golang.org/x/text/unicode/norm"
+)
 
 type Lexer struct {
+	filename     string
 	input        string
 	position     int
 	readPosition int
-	currentChar  byte
+	currentChar  rune
+	width        int
+
+	line   int
+	column int
+
+	errors diagnostic.ErrorList
 }
 
 func NewLexer(input string) *Lexer {
-	lexer := &Lexer{input: input}
+	return NewFileLexer("", input)
+}
+
+func NewFileLexer(filename string, input string) *Lexer {
+	lexer := &Lexer{filename: filename, input: input, line: 1, column: 1}
 
 	lexer.readChar()
 
 	return lexer
 }
 
+// readChar decodes the next rune of the input. Columns count runes, not
+// bytes, while offsets stay byte offsets into the input.
 func (lexer *Lexer) readChar() {
+	if lexer.width > 0 {
+		if lexer.currentChar == '\n' {
+			lexer.line++
+			lexer.column = 1
+		} else {
+			lexer.column++
+		}
+	}
+
 	if lexer.readPosition >= len(lexer.input) {
 		lexer.currentChar = 0
-	} else {
-		lexer.currentChar = lexer.input[lexer.readPosition]
+		lexer.width = 0
+		lexer.position = len(lexer.input)
+		return
 	}
+
+	lexer.currentChar, lexer.width = utf8.DecodeRuneInString(lexer.input[lexer.readPosition:])
 	lexer.position = lexer.readPosition
-	lexer.readPosition++
+	lexer.readPosition += lexer.width
+}
+
+func (lexer *Lexer) Errors() diagnostic.ErrorList {
+	return lexer.errors
+}
+
+func (lexer *Lexer) atEnd() bool {
+	return lexer.position >= len(lexer.input)
+}
+
+func (lexer *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: lexer.filename,
+		Offset:   lexer.position,
+		Line:     lexer.line,
+		Column:   lexer.column,
+	}
+}
+
+func (lexer *Lexer) spanFrom(start token.Position) token.Span {
+	return token.Span{Start: start, End: lexer.currentPosition()}
 }
 
 func (lexer *Lexer) NextToken() token.Token {
+	leadingTrivia := lexer.readLeadingTrivia()
+
+	currentToken := lexer.readToken()
+	currentToken.LeadingTrivia = leadingTrivia
+
+	if currentToken.Type != token.EOF {
+		currentToken.TrailingTrivia = lexer.readTrailingTrivia()
+	}
+
+	return currentToken
+}
+
+func (lexer *Lexer) readToken() token.Token {
 	var currentToken token.Token
 
-	lexer.skipWhitespace()
+	start := lexer.currentPosition()
 
 	switch lexer.currentChar {
 	case '=':
 		if lexer.peekNextChar() == '=' {
-			char := lexer.currentChar
-			lexer.readChar()
-			currentToken = token.Token{Type: token.EQUALS, Literal: string(char) + string(lexer.currentChar)}
+			currentToken = lexer.readTwoCharToken(token.EQUALS)
 		} else {
 			currentToken = newToken(token.ASSIGN, lexer.currentChar)
 		}
@@ -47,20 +112,44 @@ func (lexer *Lexer) NextToken() token.Token {
 		currentToken = newToken(token.MINUS, lexer.currentChar)
 	case '!':
 		if lexer.peekNextChar() == '=' {
-			char := lexer.currentChar
-			lexer.readChar()
-			currentToken = token.Token{Type: token.NOT_EQUALS, Literal: string(char) + string(lexer.currentChar)}
+			currentToken = lexer.readTwoCharToken(token.NOT_EQUALS)
 		} else {
 			currentToken = newToken(token.BANG, lexer.currentChar)
 		}
 	case '*':
-		currentToken = newToken(token.ASTERISK, lexer.currentChar)
+		if lexer.peekNextChar() == '*' {
+			currentToken = lexer.readTwoCharToken(token.POWER)
+		} else {
+			currentToken = newToken(token.ASTERISK, lexer.currentChar)
+		}
 	case '/':
 		currentToken = newToken(token.SLASH, lexer.currentChar)
+	case '%':
+		currentToken = newToken(token.PERCENT, lexer.currentChar)
 	case '<':
-		currentToken = newToken(token.LESS_THAN, lexer.currentChar)
+		if lexer.peekNextChar() == '=' {
+			currentToken = lexer.readTwoCharToken(token.LESS_EQUAL)
+		} else {
+			currentToken = newToken(token.LESS_THAN, lexer.currentChar)
+		}
 	case '>':
-		currentToken = newToken(token.GREATER_THAN, lexer.currentChar)
+		if lexer.peekNextChar() == '=' {
+			currentToken = lexer.readTwoCharToken(token.GREATER_EQUAL)
+		} else {
+			currentToken = newToken(token.GREATER_THAN, lexer.currentChar)
+		}
+	case '&':
+		if lexer.peekNextChar() == '&' {
+			currentToken = lexer.readTwoCharToken(token.AND)
+		} else {
+			currentToken = lexer.illegalCharacter(start, "did you mean `&&`?")
+		}
+	case '|':
+		if lexer.peekNextChar() == '|' {
+			currentToken = lexer.readTwoCharToken(token.OR)
+		} else {
+			currentToken = lexer.illegalCharacter(start, "did you mean `||`?")
+		}
 	case '(':
 		currentToken = newToken(token.LEFT_PARENTHESIS, lexer.currentChar)
 	case ')':
@@ -69,10 +158,19 @@ func (lexer *Lexer) NextToken() token.Token {
 		currentToken = newToken(token.COMMA, lexer.currentChar)
 	case ';':
 		currentToken = newToken(token.SEMICOLON, lexer.currentChar)
+	case ':':
+		currentToken = newToken(token.COLON, lexer.currentChar)
 	case '{':
 		currentToken = newToken(token.LEFT_BRACE, lexer.currentChar)
 	case '}':
 		currentToken = newToken(token.RIGHT_BRACE, lexer.currentChar)
+	case '[':
+		currentToken = newToken(token.LEFT_BRACKET, lexer.currentChar)
+	case ']':
+		currentToken = newToken(token.RIGHT_BRACKET, lexer.currentChar)
+	case '"':
+		currentToken.Type = token.STRING
+		currentToken.Literal = lexer.readString(start)
 	case 0:
 		currentToken.Literal = ""
 		currentToken.Type = token.EOF
@@ -80,60 +178,290 @@ func (lexer *Lexer) NextToken() token.Token {
 		if isLetter(lexer.currentChar) {
 			currentToken.Literal = lexer.readIdentifer()
 			currentToken.Type = token.LookupIdentifier(currentToken.Literal)
+			currentToken.Span = lexer.spanFrom(start)
 			return currentToken
 		} else if isDigit(lexer.currentChar) {
-			currentToken.Literal = lexer.readNumber()
-			currentToken.Type = token.INT
+			currentToken.Type, currentToken.Literal = lexer.readNumber(start)
+			currentToken.Span = lexer.spanFrom(start)
 			return currentToken
+		} else if lexer.currentChar == utf8.RuneError && lexer.width == 1 {
+			lexer.errors.Add(diagnostic.New(diagnostic.INVALID_ENCODING, lexer.spanTo(start),
+				"invalid UTF-8 encoding"))
+			currentToken = token.Token{Type: token.ILLEGAL, Literal: lexer.input[lexer.position : lexer.position+1]}
 		} else {
-			currentToken = newToken(token.ILLEGAL, lexer.currentChar)
+			currentToken = lexer.illegalCharacter(start, "")
 		}
 	}
 
 	lexer.readChar()
 
+	currentToken.Span = lexer.spanFrom(start)
+
 	return currentToken
+}
 
+func (lexer *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	char := lexer.currentChar
+	lexer.readChar()
+	return token.Token{Type: tokenType, Literal: string(char) + string(lexer.currentChar)}
+}
+
+func (lexer *Lexer) illegalCharacter(start token.Position, note string) token.Token {
+	report := diagnostic.New(diagnostic.ILLEGAL_CHARACTER, lexer.spanTo(start), "unexpected character %q", lexer.currentChar)
+	if note != "" {
+		report.WithNote(note)
+	}
+	lexer.errors.Add(report)
+
+	return newToken(token.ILLEGAL, lexer.currentChar)
 }
 
+// readIdentifer returns the identifier in NFC form, so that visually
+// identical names spelled with precomposed or combining characters match.
 func (lexer *Lexer) readIdentifer() string {
 	position := lexer.position
-	for isLetter(lexer.currentChar) {
+	for isIdentifierPart(lexer.currentChar) {
 		lexer.readChar()
 	}
-	return lexer.input[position:lexer.position]
+	return norm.NFC.String(lexer.input[position:lexer.position])
+}
+
+func isLetter(char rune) bool {
+	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_' ||
+		char >= utf8.RuneSelf && unicode.IsLetter(char)
 }
 
-func isLetter(char byte) bool {
-	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_'
+func isIdentifierPart(char rune) bool {
+	return isLetter(char) || isDigit(char) ||
+		char >= utf8.RuneSelf && (unicode.IsDigit(char) || unicode.IsMark(char))
 }
 
-func newToken(tokenType token.TokenType, char byte) token.Token {
+func newToken(tokenType token.TokenType, char rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(char)}
 }
 
-func isDigit(char byte) bool {
+func isDigit(char rune) bool {
 	return '0' <= char && char <= '9'
 }
 
-func (lexer *Lexer) readNumber() string {
-	position := lexer.position
-	for isDigit(lexer.currentChar) {
+func (lexer *Lexer) skipWhitespace() {
+	for lexer.currentChar == ' ' || lexer.currentChar == '\t' || lexer.currentChar == '\n' || lexer.currentChar == '\r' {
 		lexer.readChar()
 	}
-	return lexer.input[position:lexer.position]
 }
 
-func (lexer *Lexer) skipWhitespace() {
-	for lexer.currentChar == ' ' || lexer.currentChar == '\t' || lexer.currentChar == '\n' || lexer.currentChar == '\r' {
+func (lexer *Lexer) readLeadingTrivia() []token.Trivia {
+	var trivia []token.Trivia
+
+	for {
+		lexer.skipWhitespace()
+
+		if !lexer.atCommentStart() {
+			return trivia
+		}
+
+		trivia = append(trivia, lexer.readComment())
+	}
+}
+
+// readTrailingTrivia collects the comments after a token up to the end of
+// its line. Whatever follows the newline belongs to the next token.
+func (lexer *Lexer) readTrailingTrivia() []token.Trivia {
+	var trivia []token.Trivia
+
+	for {
+		for lexer.currentChar == ' ' || lexer.currentChar == '\t' || lexer.currentChar == '\r' && lexer.peekNextChar() != '\n' {
+			lexer.readChar()
+		}
+
+		if !lexer.atCommentStart() {
+			return trivia
+		}
+
+		comment := lexer.readComment()
+		trivia = append(trivia, comment)
+
+		if comment.Kind == token.LINE_COMMENT {
+			return trivia
+		}
+	}
+}
+
+func (lexer *Lexer) atCommentStart() bool {
+	if lexer.position == 0 && lexer.currentChar == '#' && lexer.peekNextChar() == '!' {
+		return true
+	}
+	return lexer.currentChar == '/' && (lexer.peekNextChar() == '/' || lexer.peekNextChar() == '*')
+}
+
+func (lexer *Lexer) readComment() token.Trivia {
+	start := lexer.currentPosition()
+	position := lexer.position
+
+	if lexer.currentChar == '#' || lexer.peekNextChar() == '/' {
+		kind := token.LINE_COMMENT
+		if lexer.currentChar == '#' {
+			kind = token.SHEBANG
+		}
+
+		for lexer.currentChar != '\n' && !lexer.atEnd() {
+			lexer.readChar()
+		}
+
+		text := strings.TrimSuffix(lexer.input[position:lexer.position], "\r")
+		return token.Trivia{Kind: kind, Text: text, Span: lexer.spanFrom(start)}
+	}
+
+	depth := 0
+
+	for {
+		if lexer.atEnd() {
+			lexer.errors.Add(diagnostic.New(diagnostic.UNTERMINATED_COMMENT, lexer.spanFrom(start),
+				"block comment not terminated").
+				WithNote("block comments nest, every `/*` needs its own `*/`"))
+			break
+		}
+
+		if lexer.currentChar == '/' && lexer.peekNextChar() == '*' {
+			depth++
+			lexer.readChar()
+		} else if lexer.currentChar == '*' && lexer.peekNextChar() == '/' {
+			depth--
+			lexer.readChar()
+			if depth == 0 {
+				lexer.readChar()
+				break
+			}
+		}
+
 		lexer.readChar()
 	}
+
+	return token.Trivia{Kind: token.BLOCK_COMMENT, Text: lexer.input[position:lexer.position], Span: lexer.spanFrom(start)}
 }
 
-func (lexer *Lexer) peekNextChar() byte {
+func (lexer *Lexer) peekNextChar() rune {
 	if lexer.readPosition >= len(lexer.input) {
 		return 0
 	} else {
-		return lexer.input[lexer.readPosition]
+		char, _ := utf8.DecodeRuneInString(lexer.input[lexer.readPosition:])
+		return char
+	}
+}
+
+func (lexer *Lexer) readString(start token.Position) string {
+	var out strings.Builder
+
+	for {
+		lexer.readChar()
+
+		switch {
+		case lexer.atEnd():
+			lexer.errors.Add(diagnostic.New(diagnostic.UNTERMINATED_STRING, lexer.spanFrom(start),
+				"string literal not terminated").
+				WithFix(diagnostic.Fix{
+					Message:     "insert a closing `\"`",
+					Span:        token.Span{Start: lexer.currentPosition(), End: lexer.currentPosition()},
+					Replacement: "\"",
+				}))
+			return out.String()
+		case lexer.currentChar == '"':
+			return out.String()
+		case lexer.currentChar == '\\':
+			lexer.readEscape(&out)
+		default:
+			out.WriteRune(lexer.currentChar)
+		}
+	}
+}
+
+func (lexer *Lexer) readEscape(out *strings.Builder) {
+	start := lexer.currentPosition()
+
+	lexer.readChar()
+
+	switch lexer.currentChar {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '"':
+		out.WriteByte('"')
+	case '\\':
+		out.WriteByte('\\')
+	case 'u':
+		lexer.readUnicodeEscape(start, out)
+	default:
+		if lexer.atEnd() {
+			return
+		}
+		lexer.errors.Add(diagnostic.New(diagnostic.INVALID_ESCAPE, lexer.spanTo(start),
+			"unknown escape sequence \\%c", lexer.currentChar).
+			WithNote("valid escapes are \\n, \\t, \\r, \\\", \\\\ and \\u{...}"))
+		out.WriteRune(lexer.currentChar)
+	}
+}
+
+func (lexer *Lexer) readUnicodeEscape(start token.Position, out *strings.Builder) {
+	if lexer.peekNextChar() != '{' {
+		lexer.errors.Add(diagnostic.New(diagnostic.INVALID_ESCAPE, lexer.spanTo(start),
+			"\\u must be followed by a code point in braces, like \\u{1F600}"))
+		return
+	}
+
+	lexer.readChar()
+
+	digits := lexer.position + 1
+	for isHexDigit(lexer.peekNextChar()) {
+		lexer.readChar()
+	}
+	hex := lexer.input[digits : lexer.position+1]
+
+	if lexer.peekNextChar() != '}' || len(hex) == 0 || len(hex) > 6 {
+		lexer.errors.Add(diagnostic.New(diagnostic.INVALID_ESCAPE, lexer.spanTo(start),
+			"malformed unicode escape, expected 1 to 6 hex digits in braces"))
+		return
+	}
+
+	lexer.readChar()
+
+	var codePoint rune
+	for _, digit := range hex {
+		codePoint = codePoint<<4 | hexValue(digit)
+	}
+
+	if !utf8.ValidRune(codePoint) {
+		lexer.errors.Add(diagnostic.New(diagnostic.INVALID_ESCAPE, lexer.spanTo(start),
+			"\\u{%s} is not a valid unicode code point", hex))
+		return
+	}
+
+	out.WriteRune(codePoint)
+}
+
+// spanTo returns the span from start up to and including the current char.
+func (lexer *Lexer) spanTo(start token.Position) token.Span {
+	end := lexer.currentPosition()
+	if !lexer.atEnd() {
+		end.Offset += lexer.width
+		end.Column++
+	}
+	return token.Span{Start: start, End: end}
+}
+
+func isHexDigit(char rune) bool {
+	return isDigit(char) || 'a' <= char && char <= 'f' || 'A' <= char && char <= 'F'
+}
+
+func hexValue(char rune) rune {
+	switch {
+	case isDigit(char):
+		return char - '0'
+	case 'a' <= char && char <= 'f':
+		return char - 'a' + 10
+	default:
+		return char - 'A' + 10
 	}
 }
@@ -15,7 +15,7 @@ func TestNextToken(t *testing.T) {
 	};
 
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -26,6 +26,8 @@ func TestNextToken(t *testing.T) {
 
 	10 == 10;
 	10 != 9;
+	[1, 2];
+	{"foo": "bar"}
 	`
 
 	tests := []struct {
@@ -105,6 +107,17 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQUALS, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.LEFT_BRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RIGHT_BRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LEFT_BRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RIGHT_BRACE, "}"},
 		{token.EOF, ""},
 	}
 
@@ -122,3 +135,435 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\r\nlet yy = x\n\n  + 10;"
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, token.Position{Filename: "test.monkey", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "test.monkey", Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Filename: "test.monkey", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "test.monkey", Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "test.monkey", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "test.monkey", Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Filename: "test.monkey", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "test.monkey", Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Filename: "test.monkey", Offset: 9, Line: 1, Column: 10}, token.Position{Filename: "test.monkey", Offset: 10, Line: 1, Column: 11}},
+		{token.LET, token.Position{Filename: "test.monkey", Offset: 12, Line: 2, Column: 1}, token.Position{Filename: "test.monkey", Offset: 15, Line: 2, Column: 4}},
+		{token.IDENT, token.Position{Filename: "test.monkey", Offset: 16, Line: 2, Column: 5}, token.Position{Filename: "test.monkey", Offset: 18, Line: 2, Column: 7}},
+		{token.ASSIGN, token.Position{Filename: "test.monkey", Offset: 19, Line: 2, Column: 8}, token.Position{Filename: "test.monkey", Offset: 20, Line: 2, Column: 9}},
+		{token.IDENT, token.Position{Filename: "test.monkey", Offset: 21, Line: 2, Column: 10}, token.Position{Filename: "test.monkey", Offset: 22, Line: 2, Column: 11}},
+		{token.ADD, token.Position{Filename: "test.monkey", Offset: 26, Line: 4, Column: 3}, token.Position{Filename: "test.monkey", Offset: 27, Line: 4, Column: 4}},
+		{token.INT, token.Position{Filename: "test.monkey", Offset: 28, Line: 4, Column: 5}, token.Position{Filename: "test.monkey", Offset: 30, Line: 4, Column: 7}},
+		{token.SEMICOLON, token.Position{Filename: "test.monkey", Offset: 30, Line: 4, Column: 7}, token.Position{Filename: "test.monkey", Offset: 31, Line: 4, Column: 8}},
+		{token.EOF, token.Position{Filename: "test.monkey", Offset: 31, Line: 4, Column: 8}, token.Position{Filename: "test.monkey", Offset: 31, Line: 4, Column: 8}},
+		{token.EOF, token.Position{Filename: "test.monkey", Offset: 31, Line: 4, Column: 8}, token.Position{Filename: "test.monkey", Offset: 31, Line: 4, Column: 8}},
+	}
+
+	lexer := NewFileLexer("test.monkey", input)
+
+	for i, tt := range tests {
+		token := lexer.NextToken()
+		if token.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, token.Type)
+		}
+		if token.Span.Start != tt.expectedStart {
+			t.Errorf("tests[%d] - start wrong. expected=%+v, got=%+v", i, tt.expectedStart, token.Span.Start)
+		}
+		if token.Span.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, token.Span.End)
+		}
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	input := `"foobar" "foo bar" "tab\there" "quote \" and \\ backslash" "line\nbreak" "\u{48}\u{1F600}" ""`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.STRING, "tab\there"},
+		{token.STRING, `quote " and \ backslash`},
+		{token.STRING, "line\nbreak"},
+		{token.STRING, "H\U0001F600"},
+		{token.STRING, ""},
+		{token.EOF, ""},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range tests {
+		token := lexer.NextToken()
+		if token.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, token.Type)
+		}
+		if token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, token.Literal)
+		}
+	}
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", lexer.Errors())
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"abc`, "abc", "1:1: error[E0006]: string literal not terminated"},
+		{`"a\qb"`, "aqb", `1:3: error[E0007]: unknown escape sequence \q`},
+		{`"\u{110000}"`, "", `1:2: error[E0007]: \u{110000} is not a valid unicode code point`},
+		{`"\u{}"`, "}", "1:2: error[E0007]: malformed unicode escape, expected 1 to 6 hex digits in braces"},
+		{`"\u41"`, "41", `1:2: error[E0007]: \u must be followed by a code point in braces, like \u{1F600}`},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.input)
+
+		token := lexer.NextToken()
+		if token.Literal != tt.expectedLiteral {
+			t.Errorf("input %s - literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, token.Literal)
+		}
+
+		errors := lexer.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %s - expected 1 error, got %d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("input %s - error wrong. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := "let café = 1; 変数 + naïve2 + cafe\u0301 + x_1; \"😀 ok\" Ωmega"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 5},
+		{token.ASSIGN, "=", 10},
+		{token.INT, "1", 12},
+		{token.SEMICOLON, ";", 13},
+		{token.IDENT, "変数", 15},
+		{token.ADD, "+", 18},
+		{token.IDENT, "naïve2", 20},
+		{token.ADD, "+", 27},
+		{token.IDENT, "café", 29},
+		{token.ADD, "+", 35},
+		{token.IDENT, "x_1", 37},
+		{token.SEMICOLON, ";", 40},
+		{token.STRING, "😀 ok", 42},
+		{token.IDENT, "Ωmega", 49},
+		{token.EOF, "", 54},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range tests {
+		token := lexer.NextToken()
+		if token.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, token.Type)
+		}
+		if token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, token.Literal)
+		}
+		if token.Span.Start.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, token.Span.Start.Column)
+		}
+	}
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", lexer.Errors())
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	lexer := NewLexer("a \xff b")
+
+	expected := []token.TokenType{token.IDENT, token.ILLEGAL, token.IDENT, token.EOF}
+	for i, tokenType := range expected {
+		if token := lexer.NextToken(); token.Type != tokenType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tokenType, token.Type)
+		}
+	}
+
+	errors := lexer.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:3: error[E0008]: invalid UTF-8 encoding" {
+		t.Errorf("unexpected lexer errors: %v", errors)
+	}
+}
+
+func TestCommentsAsTrivia(t *testing.T) {
+	input := `// header
+/* block /* nested */ still comment */
+let x = 5; // trailing
+/* before */ x / 2 /* after */ + 1
+// last`
+
+	type expectedToken struct {
+		expectedType     token.TokenType
+		expectedLeading  []string
+		expectedTrailing []string
+	}
+
+	tests := []expectedToken{
+		{token.LET, []string{"// header", "/* block /* nested */ still comment */"}, nil},
+		{token.IDENT, nil, nil},
+		{token.ASSIGN, nil, nil},
+		{token.INT, nil, nil},
+		{token.SEMICOLON, nil, []string{"// trailing"}},
+		{token.IDENT, []string{"/* before */"}, nil},
+		{token.SLASH, nil, nil},
+		{token.INT, nil, []string{"/* after */"}},
+		{token.ADD, nil, nil},
+		{token.INT, nil, nil},
+		{token.EOF, []string{"// last"}, nil},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range tests {
+		token := lexer.NextToken()
+		if token.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, token.Type)
+		}
+		if !triviaTextsEqual(token.LeadingTrivia, tt.expectedLeading) {
+			t.Errorf("tests[%d] - leading trivia wrong. expected=%q, got=%+v", i, tt.expectedLeading, token.LeadingTrivia)
+		}
+		if !triviaTextsEqual(token.TrailingTrivia, tt.expectedTrailing) {
+			t.Errorf("tests[%d] - trailing trivia wrong. expected=%q, got=%+v", i, tt.expectedTrailing, token.TrailingTrivia)
+		}
+	}
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", lexer.Errors())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	lexer := NewLexer("1 /* open /* nested */")
+
+	lexer.NextToken()
+	if next := lexer.NextToken(); next.Type != token.EOF {
+		t.Fatalf("expected EOF after unterminated comment. got=%q", next.Type)
+	}
+
+	errors := lexer.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:3: error[E0009]: block comment not terminated" {
+		t.Errorf("unexpected lexer errors: %v", errors)
+	}
+}
+
+func triviaTextsEqual(trivia []token.Trivia, expected []string) bool {
+	if len(trivia) != len(expected) {
+		return false
+	}
+	for i, comment := range trivia {
+		if comment.Text != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := "42 3.14 1e-9 2.5E+3 0xff 0XFF 0o17 0b1010 1_000_000 0x_dead_beef 6.022_140e23"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "42"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "0xff"},
+		{token.INT, "0XFF"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x_dead_beef"},
+		{token.FLOAT, "6.022_140e23"},
+		{token.EOF, ""},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range tests {
+		token := lexer.NextToken()
+		if token.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, token.Type)
+		}
+		if token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, token.Literal)
+		}
+	}
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", lexer.Errors())
+	}
+}
+
+func TestInvalidNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"0x", "1:1: error[E0003]: invalid number literal 0x: no digits after the 0x prefix"},
+		{"0b102", "1:1: error[E0003]: invalid number literal 0b102: digit '2' is not valid in base 2"},
+		{"0o78", "1:1: error[E0003]: invalid number literal 0o78: digit '8' is not valid in base 8"},
+		{"1__000", "1:1: error[E0003]: invalid number literal 1__000: '_' must separate successive digits"},
+		{"100_", "1:1: error[E0003]: invalid number literal 100_: '_' must separate successive digits"},
+		{"1_.5", "1:1: error[E0003]: invalid number literal 1_.5: '_' must separate successive digits"},
+		{"1e", "1:1: error[E0003]: invalid number literal 1e: exponent has no digits"},
+		{"1e+", "1:1: error[E0003]: invalid number literal 1e+: exponent has no digits"},
+		{"123abc", "1:1: error[E0003]: invalid number literal 123abc: unexpected character 'a'"},
+		{"0xfg", "1:1: error[E0003]: invalid number literal 0xfg: unexpected character 'g'"},
+		{"017", "1:1: error[E0003]: invalid number literal 017: a decimal integer cannot start with 0"},
+		{"0_9", "1:1: error[E0003]: invalid number literal 0_9: a decimal integer cannot start with 0"},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.input)
+
+		if next := lexer.NextToken(); next.Type != token.ILLEGAL || next.Literal != tt.input {
+			t.Errorf("input %s - expected a single ILLEGAL token, got %q %q", tt.input, next.Type, next.Literal)
+		}
+
+		errors := lexer.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %s - expected 1 error, got %d: %v", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("input %s - error wrong. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+	}
+}
+
+func TestLeadingZeroLiterals(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedNote string
+		expectedFix  string
+	}{
+		{"017", "write 0o17 for an octal number or 17 for a decimal one", "0o17"},
+		{"00", "write 0o0 for an octal number or 0 for a decimal one", "0o0"},
+		{"0_9", "write 9 for a decimal number", ""},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.input)
+		lexer.NextToken()
+
+		errors := lexer.Errors()
+		if len(errors) != 1 {
+			t.Fatalf("input %s - expected 1 error, got %d: %v", tt.input, len(errors), errors)
+		}
+		if len(errors[0].Notes) != 1 || errors[0].Notes[0] != tt.expectedNote {
+			t.Errorf("input %s - notes wrong. expected=%q, got=%q", tt.input, tt.expectedNote, errors[0].Notes)
+		}
+
+		fix := ""
+		if len(errors[0].Fixes) == 1 {
+			fix = errors[0].Fixes[0].Replacement
+		}
+		if fix != tt.expectedFix {
+			t.Errorf("input %s - fix wrong. expected=%q, got=%q", tt.input, tt.expectedFix, fix)
+		}
+	}
+
+	for _, input := range []string{"0", "0.5", "0e3", "0x0f", "10"} {
+		lexer := NewLexer(input)
+		if next := lexer.NextToken(); next.Type == token.ILLEGAL || len(lexer.Errors()) != 0 {
+			t.Errorf("input %s - unexpected error: %v", input, lexer.Errors())
+		}
+	}
+}
+
+func TestTwoCharacterOperators(t *testing.T) {
+	input := `a <= b >= c && d || e % f ** g * h < i > j & k | l`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LESS_EQUAL, "<="},
+		{token.IDENT, "b"},
+		{token.GREATER_EQUAL, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.POWER, "**"},
+		{token.IDENT, "g"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "h"},
+		{token.LESS_THAN, "<"},
+		{token.IDENT, "i"},
+		{token.GREATER_THAN, ">"},
+		{token.IDENT, "j"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "k"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "l"},
+		{token.EOF, ""},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range tests {
+		token := lexer.NextToken()
+		if token.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, token.Type)
+		}
+		if token.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, token.Literal)
+		}
+	}
+
+	if len(lexer.Errors()) != 2 {
+		t.Errorf("expected 2 lexer errors for single & and |, got %v", lexer.Errors())
+	}
+}
+
+func TestShebang(t *testing.T) {
+	lexer := NewLexer("#!/usr/bin/env monkey\nlet x = 1; # no")
+
+	next := lexer.NextToken()
+	if next.Type != token.LET {
+		t.Fatalf("first token wrong. got=%q", next.Type)
+	}
+
+	if len(next.LeadingTrivia) != 1 {
+		t.Fatalf("wrong number of leading trivia. got=%d", len(next.LeadingTrivia))
+	}
+
+	shebang := next.LeadingTrivia[0]
+	if shebang.Kind != token.SHEBANG || shebang.Text != "#!/usr/bin/env monkey" {
+		t.Errorf("shebang wrong. got=%+v", shebang)
+	}
+
+	for next.Type != token.EOF && next.Type != token.ILLEGAL {
+		next = lexer.NextToken()
+	}
+
+	if next.Type != token.ILLEGAL || next.Literal != "#" {
+		t.Errorf("`#` after the first line should be illegal. got=%q %q", next.Type, next.Literal)
+	}
+}
@@ -0,0 +1,205 @@
+package lexer
+
+import (
+	"strings"
+
+	"github.com/Favot/monkey-interpreter/diagnostic"
+	"github.com/Favot/monkey-interpreter/token"
+)
+
+// readNumber reads an integer or float literal. Integers may be written in
+// decimal, or in hexadecimal, octal or binary with a 0x, 0o or 0b prefix.
+// Floats are decimal with a fraction, an exponent or both. Any literal may
+// use `_` between digits as a separator. The literal is returned as written
+// and malformed literals are reported here, so the parser only has to check
+// that the value fits.
+func (lexer *Lexer) readNumber(start token.Position) (token.TokenType, string) {
+	position := lexer.position
+	tokenType := token.TokenType(token.INT)
+	base := 10
+
+	if lexer.currentChar == '0' {
+		switch lexer.peekNextChar() {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+
+	if base != 10 {
+		lexer.readChar()
+		lexer.readChar()
+		lexer.readDigits(isHexDigit)
+	} else {
+		lexer.readDigits(isDigit)
+
+		if lexer.currentChar == '.' && isDigit(lexer.peekNextChar()) {
+			tokenType = token.FLOAT
+			lexer.readChar()
+			lexer.readDigits(isDigit)
+		}
+
+		if lexer.currentChar == 'e' || lexer.currentChar == 'E' {
+			tokenType = token.FLOAT
+			lexer.readChar()
+			if lexer.currentChar == '+' || lexer.currentChar == '-' {
+				lexer.readChar()
+			}
+			lexer.readDigits(isDigit)
+		}
+	}
+
+	numberEnd := lexer.position
+
+	for isIdentifierPart(lexer.currentChar) {
+		lexer.readChar()
+	}
+
+	literal := lexer.input[position:lexer.position]
+
+	message := validateNumber(lexer.input[position:numberEnd], base)
+	if message == "" && numberEnd < lexer.position {
+		message = "unexpected character " + quoteChar([]rune(lexer.input[numberEnd:lexer.position])[0])
+	}
+
+	if message != "" {
+		lexer.errors.Add(diagnostic.New(diagnostic.INVALID_NUMBER, lexer.spanFrom(start),
+			"invalid number literal %s: %s", literal, message))
+		return token.ILLEGAL, literal
+	}
+
+	if tokenType == token.INT && base == 10 && len(strings.ReplaceAll(literal, "_", "")) > 1 && literal[0] == '0' {
+		lexer.errors.Add(lexer.leadingZeroError(start, literal))
+		return token.ILLEGAL, literal
+	}
+
+	return tokenType, literal
+}
+
+// IntegerDigits strips the base prefix and digit separators the lexer
+// accepted, leaving digits strconv can parse in the returned base.
+func IntegerDigits(literal string) (string, int) {
+	digits := strings.ReplaceAll(literal, "_", "")
+	base := 10
+
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+
+	if base != 10 {
+		digits = digits[2:]
+	}
+
+	return digits, base
+}
+
+// leadingZeroError reports a decimal integer written with a leading zero,
+// which C, Go and older releases of Monkey read as octal. The fix keeps
+// that octal value.
+func (lexer *Lexer) leadingZeroError(start token.Position, literal string) *diagnostic.Diagnostic {
+	digits := strings.TrimLeft(literal, "0_")
+	if digits == "" {
+		digits = "0"
+	}
+
+	report := diagnostic.New(diagnostic.INVALID_NUMBER, lexer.spanFrom(start),
+		"invalid number literal %s: a decimal integer cannot start with 0", literal)
+
+	if strings.Trim(digits, "01234567_") != "" {
+		return report.WithNote("write %s for a decimal number", digits)
+	}
+
+	return report.
+		WithNote("write 0o%s for an octal number or %s for a decimal one", digits, digits).
+		WithFix(diagnostic.Fix{
+			Message:     "write it as 0o" + digits,
+			Span:        lexer.spanFrom(start),
+			Replacement: "0o" + digits,
+		})
+}
+
+func (lexer *Lexer) readDigits(isBaseDigit func(rune) bool) {
+	for isBaseDigit(lexer.currentChar) || lexer.currentChar == '_' {
+		lexer.readChar()
+	}
+}
+
+func validateNumber(literal string, base int) string {
+	digits := literal
+	if base != 10 {
+		digits = literal[2:]
+	}
+
+	if strings.Trim(digits, "_") == "" {
+		return "no digits after the " + literal[:2] + " prefix"
+	}
+
+	for index, char := range digits {
+		if char == '_' {
+			if !isSeparated(digits, index, base) {
+				return "'_' must separate successive digits"
+			}
+			continue
+		}
+
+		if base != 10 && !isDigitInBase(char, base) {
+			return "digit " + quoteChar(char) + " is not valid in base " + baseName(base)
+		}
+	}
+
+	if base == 10 && strings.ContainsAny(digits[len(digits)-1:], "eE+-") {
+		return "exponent has no digits"
+	}
+
+	return ""
+}
+
+// isSeparated reports whether the `_` at index sits between two digits, or
+// right after a base prefix.
+func isSeparated(digits string, index int, base int) bool {
+	if index+1 >= len(digits) || !isDigitInBase(rune(digits[index+1]), base) {
+		return false
+	}
+	if index == 0 {
+		return base != 10
+	}
+	return isDigitInBase(rune(digits[index-1]), base)
+}
+
+func isDigitInBase(char rune, base int) bool {
+	switch base {
+	case 2:
+		return char == '0' || char == '1'
+	case 8:
+		return '0' <= char && char <= '7'
+	case 16:
+		return isHexDigit(char)
+	default:
+		return isDigit(char)
+	}
+}
+
+func baseName(base int) string {
+	switch base {
+	case 2:
+		return "2"
+	case 8:
+		return "8"
+	default:
+		return "16"
+	}
+}
+
+func quoteChar(char rune) string {
+	return "'" + string(char) + "'"
+}
@@ -1,24 +1,441 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/user"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/diagnostic"
+	"github.com/Favot/monkey-interpreter/format"
+	"github.com/Favot/monkey-interpreter/mkc"
+	"github.com/Favot/monkey-interpreter/monkey"
+	"github.com/Favot/monkey-interpreter/parser"
 	"github.com/Favot/monkey-interpreter/repl"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
+const (
+	EXIT_SUCCESS       = 0
+	EXIT_RUNTIME_ERROR = 1
+	EXIT_USAGE         = 2
+	EXIT_SYNTAX_ERROR  = 3
+	EXIT_IO_ERROR      = 4
+	EXIT_INTERRUPTED   = 130
+)
+
+const usage = `Usage:
+	monkey run [flags] <file|-> [args...]   run a script, - reads it from stdin
+	monkey build <file|-> [-o file.mkc]     compile a script to bytecode
+	monkey disasm <file|->                  print the bytecode of a script
+	monkey fmt [-w] [-d] [files...]         reformat scripts in the canonical
+	                                        style, stdin when none are given
+	monkey ast [flags] <file|->             print the syntax tree of a script
+	monkey repl                             start an interactive session
+
+Flags for run:
+	-engine=eval|vm   eval walks the syntax tree, vm compiles the script to
+	                  bytecode first (default eval)
+	-trace            print every instruction the vm executes, with the
+	                  stack, to stderr (needs -engine=vm)
+
+Flags for fmt:
+	-w                write the result back to each file instead of
+	                  printing it
+	-d                print a diff of the changes instead of the result
+
+Flags for ast:
+	-format=json|sexp json gives every node with its kind, span and
+	                  children, sexp a compact outline (default json)
+
+Scripts built to .mkc files always run on the vm engine.
+
+Running monkey without a command starts the repl.
+`
+
 func main() {
-	user, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(arguments) == 0 {
+		return runRepl(stdin, stdout)
+	}
+
+	command, arguments := arguments[0], arguments[1:]
+
+	switch command {
+	case "run":
+		return runScript(arguments, stdin, stdout, stderr)
+	case "build":
+		return buildScript(arguments, stdin, stderr)
+	case "disasm":
+		return disassembleScript(arguments, stdin, stdout, stderr)
+	case "fmt":
+		return formatScripts(arguments, stdin, stdout, stderr)
+	case "ast":
+		return printSyntaxTree(arguments, stdin, stdout, stderr)
+	case "repl":
+		return runRepl(stdin, stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return EXIT_SUCCESS
+	default:
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n\n%s", command, usage)
+		return EXIT_USAGE
+	}
+}
+
+func runRepl(stdin io.Reader, stdout io.Writer) int {
+	fmt.Fprintln(stdout, "This is the Monkey programming language.")
+	fmt.Fprintln(stdout, "Let's get started!")
+
+	repl.StartRepl(stdin, stdout)
+
+	return EXIT_SUCCESS
+}
+
+// runScript runs the script named by the first argument and exposes the
+// remaining arguments to it as the `args` array.
+func runScript(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	engine := flags.String("engine", string(monkey.ENGINE_EVAL), "engine that runs the script")
+	trace := flags.Bool("trace", false, "trace the instructions the vm executes")
+
+	if err := flags.Parse(arguments); err != nil {
+		return EXIT_USAGE
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "monkey run: missing script\n\n%s", usage)
+		return EXIT_USAGE
+	}
+
+	filename, src, err := readScript(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey run: %s\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	compiled := mkc.IsBytecode([]byte(src))
+	if compiled {
+		engineSet := false
+		flags.Visit(func(f *flag.Flag) { engineSet = engineSet || f.Name == "engine" })
+
+		if engineSet && monkey.Engine(*engine) != monkey.ENGINE_VM {
+			fmt.Fprintf(stderr, "monkey run: %s is compiled and only runs on the vm engine\n", filename)
+			return EXIT_USAGE
+		}
+		*engine = string(monkey.ENGINE_VM)
+	}
+
+	if *trace && monkey.Engine(*engine) != monkey.ENGINE_VM {
+		fmt.Fprintf(stderr, "monkey run: -trace needs -engine=vm\n\n%s", usage)
+		return EXIT_USAGE
+	}
+
+	interpreter, err := newInterpreter(monkey.Engine(*engine), stdout, flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey run: %s\n\n%s", err, usage)
+		return EXIT_USAGE
+	}
+	if *trace {
+		interpreter.Trace = stderr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if compiled {
+		bytecode, err := mkc.Decode([]byte(src))
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey run: %s: %s\n", filename, err)
+			return EXIT_IO_ERROR
+		}
+		return reportError(stderr, "", interpreter.RunBytecode(ctx, bytecode))
+	}
+
+	return reportError(stderr, src, interpreter.RunSource(ctx, filename, src))
+}
+
+// buildScript compiles a script and writes its bytecode to the file given
+// with -o, by default the script's name with a .mkc extension.
+func buildScript(arguments []string, stdin io.Reader, stderr io.Writer) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	output := flags.String("o", "", "file to write the bytecode to")
+
+	if err := flags.Parse(arguments); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "monkey build: missing script\n\n%s", usage)
+		return EXIT_USAGE
+	}
+
+	// Flags may also follow the script, as in `monkey build foo.monkey -o foo.mkc`.
+	path := flags.Arg(0)
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "monkey build: expected one script\n\n%s", usage)
+		return EXIT_USAGE
+	}
+
+	if *output == "" {
+		if path == "-" {
+			fmt.Fprintf(stderr, "monkey build: -o is needed to build from stdin\n\n%s", usage)
+			return EXIT_USAGE
+		}
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".mkc"
+	}
+
+	filename, src, err := readScript(path, stdin)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(stderr, "monkey build: %s\n", err)
+		return EXIT_IO_ERROR
 	}
 
-	fmt.Printf("Hello %s! This is the Monkey programming language.\n", cases.Title(language.English).String(user.Username))
+	interpreter, err := newInterpreter(monkey.ENGINE_VM, io.Discard, []string{})
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey build: %s\n", err)
+		return EXIT_RUNTIME_ERROR
+	}
+
+	bytecode, err := interpreter.Compile(filename, src)
+	if err != nil {
+		return reportError(stderr, src, err)
+	}
 
-	fmt.Println("Let's get started!")
+	data, err := mkc.Encode(bytecode)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey build: %s\n", err)
+		return EXIT_RUNTIME_ERROR
+	}
+
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintf(stderr, "monkey build: %s\n", err)
+		return EXIT_IO_ERROR
+	}
 
-	repl.StartRepl(os.Stdin, os.Stdout)
+	return EXIT_SUCCESS
+}
+
+// disassembleScript prints the bytecode of the script named by the only
+// argument, compiling it first unless it was built already.
+func disassembleScript(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(arguments) != 1 {
+		fmt.Fprintf(stderr, "monkey disasm: expected one script\n\n%s", usage)
+		return EXIT_USAGE
+	}
+
+	filename, src, err := readScript(arguments[0], stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey disasm: %s\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	interpreter, err := newInterpreter(monkey.ENGINE_VM, stdout, []string{})
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey disasm: %s\n", err)
+		return EXIT_RUNTIME_ERROR
+	}
+
+	var bytecode *compiler.Bytecode
+	if mkc.IsBytecode([]byte(src)) {
+		bytecode, err = mkc.Decode([]byte(src))
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey disasm: %s: %s\n", filename, err)
+			return EXIT_IO_ERROR
+		}
+	} else {
+		bytecode, err = interpreter.Compile(filename, src)
+		if err != nil {
+			return reportError(stderr, src, err)
+		}
+	}
+
+	return reportError(stderr, src, bytecode.Disassemble(stdout))
+}
+
+// formatScripts reformats each script named in arguments, or stdin when
+// there are none. Every script is tried even when one fails, and the exit
+// code is that of the first failure.
+func formatScripts(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	write := flags.Bool("w", false, "write the result back to each file")
+	showDiff := flags.Bool("d", false, "print a diff of the changes")
+
+	if err := flags.Parse(arguments); err != nil {
+		return EXIT_USAGE
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	code := EXIT_SUCCESS
+	for _, path := range paths {
+		if result := formatScript(path, *write, *showDiff, stdin, stdout, stderr); code == EXIT_SUCCESS {
+			code = result
+		}
+	}
+
+	return code
+}
+
+func formatScript(path string, write, showDiff bool, stdin io.Reader, stdout, stderr io.Writer) int {
+	if write && path == "-" {
+		fmt.Fprintf(stderr, "monkey fmt: -w needs files, it cannot write back to stdin\n\n%s", usage)
+		return EXIT_USAGE
+	}
+
+	filename, src, err := readScript(path, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+		return EXIT_IO_ERROR
+	}
+	if mkc.IsBytecode([]byte(src)) {
+		fmt.Fprintf(stderr, "monkey fmt: %s is compiled, format its source instead\n", filename)
+		return EXIT_USAGE
+	}
+
+	formatted, err := format.Source(filename, src)
+	if err != nil {
+		return reportError(stderr, src, err)
+	}
+
+	if showDiff {
+		fmt.Fprint(stdout, diff(filename+".orig", filename, src, formatted))
+	}
+
+	if !write {
+		if !showDiff {
+			fmt.Fprint(stdout, formatted)
+		}
+		return EXIT_SUCCESS
+	}
+
+	if formatted == src {
+		return EXIT_SUCCESS
+	}
+
+	info, err := os.Stat(path)
+	if err == nil {
+		err = os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	return EXIT_SUCCESS
+}
+
+// printSyntaxTree prints the syntax tree of the script named by the only
+// argument, as JSON or as an S-expression.
+func printSyntaxTree(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	outputFormat := flags.String("format", "json", "json or sexp")
+
+	if err := flags.Parse(arguments); err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "monkey ast: expected one script\n\n%s", usage)
+		return EXIT_USAGE
+	}
+	if *outputFormat != "json" && *outputFormat != "sexp" {
+		fmt.Fprintf(stderr, "monkey ast: unknown format %q\n\n%s", *outputFormat, usage)
+		return EXIT_USAGE
+	}
+
+	filename, src, err := readScript(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %s\n", err)
+		return EXIT_IO_ERROR
+	}
+
+	program, err := parser.ParseSource(filename, src)
+	if err != nil {
+		return reportError(stderr, src, err)
+	}
+
+	if *outputFormat == "sexp" {
+		fmt.Fprintln(stdout, abstractSyntaxTree.SExpression(program))
+		return EXIT_SUCCESS
+	}
+
+	data, err := abstractSyntaxTree.EncodeJSON(program)
+	if err != nil {
+		return reportError(stderr, src, err)
+	}
+	fmt.Fprintf(stdout, "%s\n", data)
+
+	return EXIT_SUCCESS
+}
+
+// newInterpreter returns an interpreter with the globals every script can
+// use, so that a script compiles to the same global slots whichever command
+// compiles it.
+func newInterpreter(engine monkey.Engine, stdout io.Writer, args []string) (*monkey.Interpreter, error) {
+	interpreter, err := monkey.NewInterpreterWithEngine(engine)
+	if err != nil {
+		return nil, err
+	}
+	interpreter.Stdout = stdout
+
+	if err := interpreter.Set("args", args); err != nil {
+		return nil, err
+	}
+
+	return interpreter, nil
+}
+
+func readScript(path string, stdin io.Reader) (string, string, error) {
+	if path == "-" {
+		src, err := io.ReadAll(stdin)
+		return "<stdin>", string(src), err
+	}
+
+	src, err := os.ReadFile(path)
+	return path, string(src), err
+}
+
+// reportError prints err to stderr and returns the exit code it maps to.
+func reportError(stderr io.Writer, src string, err error) int {
+	var errorList diagnostic.ErrorList
+	var runtimeError *monkey.RuntimeError
+
+	switch {
+	case err == nil:
+		return EXIT_SUCCESS
+	case errors.As(err, &errorList):
+		diagnostic.RenderAll(stderr, src, errorList)
+		return EXIT_SYNTAX_ERROR
+	case errors.As(err, &runtimeError):
+		fmt.Fprintf(stderr, "error: %s\n", runtimeError.Message)
+		return EXIT_RUNTIME_ERROR
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(stderr, "interrupted")
+		return EXIT_INTERRUPTED
+	default:
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return EXIT_RUNTIME_ERROR
+	}
 }
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/format"
+	"github.com/Favot/monkey-interpreter/mkc"
+)
+
+func TestRun(t *testing.T) {
+	directory := t.TempDir()
+
+	script := filepath.Join(directory, "hello.monkey")
+	err := os.WriteFile(script, []byte("#!/usr/bin/env monkey\nputs(\"hello\", len(args), args);\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments      []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			[]string{"run", script, "a", "-b"},
+			"",
+			EXIT_SUCCESS,
+			"hello\n2\n[a, -b]\n",
+			"",
+		},
+		{
+			[]string{"run", "-", "x"},
+			"puts(args[0] + \"!\")",
+			EXIT_SUCCESS,
+			"x!\n",
+			"",
+		},
+		{
+			[]string{"run", "-"},
+			"#!/bin/monkey\nlet x = ;",
+			EXIT_SYNTAX_ERROR,
+			"",
+			"<stdin>:2:9: error[E0002]: expected an expression, got `;`\n  |\n2 | let x = ;\n  |         ^\n",
+		},
+		{
+			[]string{"run", "-"},
+			"puts(1); 1 + true; puts(2);",
+			EXIT_RUNTIME_ERROR,
+			"1\n",
+			"error: type mismatch: INTEGER + BOOLEAN\n",
+		},
+		{
+			[]string{"run", filepath.Join(directory, "missing.monkey")},
+			"",
+			EXIT_IO_ERROR,
+			"",
+			"monkey run: open " + filepath.Join(directory, "missing.monkey") + ": no such file or directory\n",
+		},
+		{
+			[]string{"run", "-engine=vm", script, "a"},
+			"",
+			EXIT_SUCCESS,
+			"hello\n1\n[a]\n",
+			"",
+		},
+		{
+			[]string{"run", "-engine=vm", "-"},
+			"puts(1); 1 + true; puts(2);",
+			EXIT_RUNTIME_ERROR,
+			"1\n",
+			"error: type mismatch: INTEGER + BOOLEAN\n",
+		},
+		{
+			[]string{"run", "-engine=jit", "-"},
+			"",
+			EXIT_USAGE,
+			"",
+			"monkey run: unknown engine \"jit\"\n\n" + usage,
+		},
+		{
+			[]string{"run", "-engine=vm", "-trace", "-"},
+			"puts(1)",
+			EXIT_SUCCESS,
+			"1\n",
+			"main         0000    1  OpGetGlobal global=0         []\n" +
+				"main         0003    1  OpConstant constant=0        [builtin puts]\n" +
+				"main         0006    1  OpCall arguments=1           [builtin puts, 1]\n" +
+				"main         0008    1  OpPop                        [null]\n",
+		},
+		{
+			[]string{"run", "-trace", "-"},
+			"",
+			EXIT_USAGE,
+			"",
+			"monkey run: -trace needs -engine=vm\n\n" + usage,
+		},
+		{
+			[]string{"disasm", "-"},
+			"let x = 1;\nputs(x, args);",
+			EXIT_SUCCESS,
+			"== main ==\n" +
+				"0000    1  OpConstant constant=0            ; 1\n" +
+				"0003    |  OpSetGlobal global=2             ; x\n" +
+				"0006    2  OpGetGlobal global=0             ; puts\n" +
+				"0009    |  OpGetGlobal global=2             ; x\n" +
+				"0012    |  OpGetGlobal global=1             ; args\n" +
+				"0015    |  OpCall arguments=2\n" +
+				"0017    |  OpPop\n",
+			"",
+		},
+		{
+			[]string{"disasm", "-"},
+			"len(" + strings.Repeat("1, ", 255) + "1)",
+			EXIT_RUNTIME_ERROR,
+			"",
+			"error: program too large: OpCall operand arguments=256 is more than the limit of 255\n",
+		},
+		{
+			[]string{"disasm"},
+			"",
+			EXIT_USAGE,
+			"",
+			"monkey disasm: expected one script\n\n" + usage,
+		},
+		{
+			[]string{"run"},
+			"",
+			EXIT_USAGE,
+			"",
+			"monkey run: missing script\n\n" + usage,
+		},
+		{
+			[]string{"walk"},
+			"",
+			EXIT_USAGE,
+			"",
+			"monkey: unknown command \"walk\"\n\n" + usage,
+		},
+		{
+			[]string{"help"},
+			"",
+			EXIT_SUCCESS,
+			usage,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(tt.arguments, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d", tt.arguments, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	directory := t.TempDir()
+
+	script := filepath.Join(directory, "greet.monkey")
+	err := os.WriteFile(script, []byte("#!/usr/bin/env monkey\nlet greet = fn(name) { \"hi \" + name };\nputs(greet(args[0]));\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compiled := filepath.Join(directory, "out.mkc")
+
+	tests := []struct {
+		arguments      []string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"build", script, "-o", compiled}, EXIT_SUCCESS, "", ""},
+		{[]string{"run", compiled, "bob"}, EXIT_SUCCESS, "hi bob\n", ""},
+		{[]string{"run", "-engine=vm", compiled, "ann"}, EXIT_SUCCESS, "hi ann\n", ""},
+		{[]string{"run", compiled}, EXIT_RUNTIME_ERROR, "", "error: type mismatch: STRING + NULL\n"},
+		{
+			[]string{"run", "-engine=eval", compiled},
+			EXIT_USAGE,
+			"",
+			"monkey run: " + compiled + " is compiled and only runs on the vm engine\n",
+		},
+		{[]string{"build", script}, EXIT_SUCCESS, "", ""},
+		{[]string{"run", filepath.Join(directory, "greet.mkc"), "cy"}, EXIT_SUCCESS, "hi cy\n", ""},
+		{[]string{"build", "-"}, EXIT_USAGE, "", "monkey build: -o is needed to build from stdin\n\n" + usage},
+		{[]string{"build", script, "extra"}, EXIT_USAGE, "", "monkey build: expected one script\n\n" + usage},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(tt.arguments, strings.NewReader(""), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d", tt.arguments, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+
+	data, err := os.ReadFile(compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(mkc.MAGIC)+1]++
+	if err := os.WriteFile(compiled, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"run", compiled}, strings.NewReader(""), &stdout, &stderr)
+
+	expected := fmt.Sprintf("monkey run: %s: bytecode format version %d is not supported, this monkey reads version %d: rebuild the script from source\n",
+		compiled, mkc.VERSION+1, mkc.VERSION)
+	if code != EXIT_IO_ERROR || stderr.String() != expected {
+		t.Errorf("incompatible version wrong. code=%d, stderr=%q", code, stderr.String())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	directory := t.TempDir()
+
+	script := filepath.Join(directory, "messy.monkey")
+	if err := os.WriteFile(script, []byte("let x=1\nputs( x )\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments      []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"fmt"}, "let  y = (1+2)*3", EXIT_SUCCESS, "let y = (1 + 2) * 3;\n", ""},
+		{[]string{"fmt", "-", script}, "y", EXIT_SUCCESS, "y;\nlet x = 1;\nputs(x);\n", ""},
+		{
+			[]string{"fmt", "-d", script},
+			"",
+			EXIT_SUCCESS,
+			"--- " + script + ".orig\n+++ " + script + "\n@@ -1,2 +1,2 @@\n-let x=1\n-puts( x )\n+let x = 1;\n+puts(x);\n",
+			"",
+		},
+		{[]string{"fmt", "-w", script}, "", EXIT_SUCCESS, "", ""},
+		{[]string{"fmt", "-d", script}, "", EXIT_SUCCESS, "", ""},
+		{[]string{"fmt", "-w"}, "", EXIT_USAGE, "", "monkey fmt: -w needs files, it cannot write back to stdin\n\n" + usage},
+		{
+			[]string{"fmt", "-", script},
+			"let = 1;",
+			EXIT_SYNTAX_ERROR,
+			"let x = 1;\nputs(x);\n",
+			"<stdin>:1:5: error[E0001]: expected next token to be IDENT, got = instead\n  |\n1 | let = 1;\n  |     ^\n",
+		},
+		{
+			[]string{"fmt", filepath.Join(directory, "missing.monkey")},
+			"",
+			EXIT_IO_ERROR,
+			"",
+			"monkey fmt: open " + filepath.Join(directory, "missing.monkey") + ": no such file or directory\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(tt.arguments, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d", tt.arguments, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("-w changed the file mode. got=%v", info.Mode().Perm())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		before   string
+		after    string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\n", "b\n", "--- x\n+++ y\n@@ -1 +1 @@\n-a\n+b\n"},
+		{"", "a\n", "--- x\n+++ y\n@@ -0,0 +1 @@\n+a\n"},
+		{"a", "a\n", "--- x\n+++ y\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\nthree\n4\n5\n6\n7\n8\n",
+			"--- x\n+++ y\n@@ -1,9 +1,8 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n 8\n-9\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			"--- x\n+++ y\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if actual := diff("x", "y", tt.before, tt.after); actual != tt.expected {
+			t.Errorf("diff(%q, %q) wrong.\nexpected=%q\ngot=     %q", tt.before, tt.after, tt.expected, actual)
+		}
+	}
+}
+
+func TestEditScript(t *testing.T) {
+	// Lines from a small alphabet give many different shortest scripts.
+	lines := func(seed, count int) []string {
+		result := []string{}
+		for index := 0; index < count; index++ {
+			seed = (seed*1103515245 + 12345) % (1 << 31)
+			result = append(result, string(rune('a'+seed%4))+"\n")
+		}
+		return result
+	}
+
+	for seed := 0; seed < 50; seed++ {
+		before, after := lines(seed, seed%13), lines(seed+100, seed%7+3)
+
+		var gotBefore, gotAfter []string
+		changes := 0
+		for _, edit := range editScript(before, after) {
+			if edit.kind != '+' {
+				gotBefore = append(gotBefore, edit.line)
+			}
+			if edit.kind != '-' {
+				gotAfter = append(gotAfter, edit.line)
+			}
+			if edit.kind != ' ' {
+				changes++
+			}
+		}
+
+		if strings.Join(gotBefore, "") != strings.Join(before, "") || strings.Join(gotAfter, "") != strings.Join(after, "") {
+			t.Errorf("seed %d: edits do not turn %q into %q", seed, before, after)
+		}
+		if expected := len(before) + len(after) - 2*longestCommonSubsequence(before, after); changes != expected {
+			t.Errorf("seed %d: expected %d changes, got %d", seed, expected, changes)
+		}
+	}
+}
+
+func longestCommonSubsequence(before, after []string) int {
+	lengths := make([][]int, len(before)+1)
+	for index := range lengths {
+		lengths[index] = make([]int, len(after)+1)
+	}
+
+	for x := 1; x <= len(before); x++ {
+		for y := 1; y <= len(after); y++ {
+			if before[x-1] == after[y-1] {
+				lengths[x][y] = lengths[x-1][y-1] + 1
+			} else {
+				lengths[x][y] = max(lengths[x-1][y], lengths[x][y-1])
+			}
+		}
+	}
+
+	return lengths[len(before)][len(after)]
+}
+
+func TestAst(t *testing.T) {
+	tests := []struct {
+		arguments      []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			[]string{"ast", "-format=sexp", "-"},
+			"let x = -1;\nputs(x)",
+			EXIT_SUCCESS,
+			"(Program\n" +
+				"  (LetStatement (Identifier x) (PrefixExpression - (IntegerLiteral 1)))\n" +
+				"  (ExpressionStatement (CallExpression (Identifier puts) (Identifier x))))\n",
+			"",
+		},
+		{
+			[]string{"ast", "-"},
+			"x",
+			EXIT_SUCCESS,
+			`{
+  "kind": "Program",
+  "span": {
+    "start": {
+      "line": 1,
+      "column": 1,
+      "offset": 0
+    },
+    "end": {
+      "line": 1,
+      "column": 2,
+      "offset": 1
+    }
+  },
+  "statements": [
+    {
+      "kind": "ExpressionStatement",
+      "span": {
+        "start": {
+          "line": 1,
+          "column": 1,
+          "offset": 0
+        },
+        "end": {
+          "line": 1,
+          "column": 2,
+          "offset": 1
+        }
+      },
+      "expression": {
+        "kind": "Identifier",
+        "span": {
+          "start": {
+            "line": 1,
+            "column": 1,
+            "offset": 0
+          },
+          "end": {
+            "line": 1,
+            "column": 2,
+            "offset": 1
+          }
+        },
+        "name": "x"
+      }
+    }
+  ],
+  "comments": []
+}
+`,
+			"",
+		},
+		{[]string{"ast", "-format=xml", "-"}, "", EXIT_USAGE, "", "monkey ast: unknown format \"xml\"\n\n" + usage},
+		{[]string{"ast"}, "", EXIT_USAGE, "", "monkey ast: expected one script\n\n" + usage},
+		{
+			[]string{"ast", "-"},
+			"let = 1;",
+			EXIT_SYNTAX_ERROR,
+			"",
+			"<stdin>:1:5: error[E0001]: expected next token to be IDENT, got = instead\n  |\n1 | let = 1;\n  |     ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		code := run(tt.arguments, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.expectedCode {
+			t.Errorf("%v: exit code wrong. expected=%d, got=%d", tt.arguments, tt.expectedCode, code)
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: stdout wrong. expected=%q, got=%q", tt.arguments, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%v: stderr wrong. expected=%q, got=%q", tt.arguments, tt.expectedStderr, stderr.String())
+		}
+	}
+}
+
+// TestAstImport checks that a tree exported as JSON and imported again
+// formats to the same source, comments included.
+func TestAstImport(t *testing.T) {
+	src := "#!/usr/bin/env monkey\n" +
+		"// Doubles every element.\n" +
+		"let double = fn(array) {\n" +
+		"    map(array, fn(x) { x * 2 }); // map is a builtin\n" +
+		"};\n" +
+		"\n" +
+		"let config = {\n" +
+		"    \"name\": \"monkey\",\n" +
+		"    \"sizes\": [1, 0x10, 2.5]\n" +
+		"};\n" +
+		"if (len(config) > 1) { puts(double(config[\"sizes\"])[0]) } else { -(1 + 2) ** 2 }\n"
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"ast", "-"}, strings.NewReader(src), &stdout, &stderr); code != EXIT_SUCCESS {
+		t.Fatalf("exit code wrong. got=%d, stderr=%q", code, stderr.String())
+	}
+
+	node, err := abstractSyntaxTree.DecodeJSON(stdout.Bytes())
+	if err != nil {
+		t.Fatalf("DecodeJSON failed: %s", err)
+	}
+
+	var formatted bytes.Buffer
+	if err := format.Node(&formatted, node); err != nil {
+		t.Fatalf("format.Node failed: %s", err)
+	}
+	if formatted.String() != src {
+		t.Errorf("imported program wrong.\nexpected=%q\ngot=     %q", src, formatted.String())
+	}
+}
+
+func TestRunRepl(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"repl"}, strings.NewReader("1 + 2\n"), &stdout, &stderr)
+
+	if code != EXIT_SUCCESS {
+		t.Errorf("exit code wrong. got=%d", code)
+	}
+	if !strings.Contains(stdout.String(), "Monkey >> 3\n") {
+		t.Errorf("repl output wrong. got=%q", stdout.String())
+	}
+}
@@ -0,0 +1,335 @@
+// Package mkc reads and writes compiled Monkey programs in the .mkc format,
+// so that a script can be run without parsing and compiling it again.
+//
+// A file is laid out as follows, with every integer big-endian and every
+// string a uint32 length followed by its bytes:
+//
+//	header     MAGIC, then VERSION as a uint16
+//	globals    uint32 count, then the name of each global slot
+//	functions  uint32 count, then for each function its name and source,
+//	           number of parameters and of locals as uint16s and its
+//	           instructions as a uint32 length and bytes; function 0 is the
+//	           main program
+//	constants  uint32 count, then for each constant a tag byte and its value
+//	lines      for each function, a uint32 count and each entry's offset and
+//	           line as uint32s
+//	checksum   CRC-32 (IEEE) of everything before it, as a uint32
+package mkc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+const MAGIC = "MKC\x00"
+
+// VERSION must change whenever the layout or the meaning of an opcode
+// changes, so that older files are rejected instead of misread.
+const VERSION = 3
+
+const (
+	INTEGER_CONSTANT byte = iota + 1
+	FLOAT_CONSTANT
+	STRING_CONSTANT
+	FUNCTION_CONSTANT
+	BUILTIN_CONSTANT
+)
+
+var (
+	ErrNotBytecode = errors.New("not a Monkey bytecode file")
+	ErrChecksum    = errors.New("checksum mismatch, the file is corrupt")
+)
+
+// VersionError is returned for a file written in another format version.
+type VersionError struct {
+	Version uint16
+}
+
+func (versionError *VersionError) Error() string {
+	return fmt.Sprintf("bytecode format version %d is not supported, this monkey reads version %d: rebuild the script from source",
+		versionError.Version, VERSION)
+}
+
+// IsBytecode reports whether data starts like a .mkc file.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(MAGIC))
+}
+
+// Encode serializes bytecode. Constants other than integers, floats,
+// strings, compiled functions and registered builtins cannot be encoded.
+func Encode(bytecode *compiler.Bytecode) ([]byte, error) {
+	functions := []*object.CompiledFunction{{Instructions: bytecode.Instructions, Lines: bytecode.Lines}}
+	functionIndexes := map[*object.CompiledFunction]int{}
+	for _, constant := range bytecode.Constants {
+		if function, ok := constant.(*object.CompiledFunction); ok {
+			functionIndexes[function] = len(functions)
+			functions = append(functions, function)
+		}
+	}
+
+	encoder := &encoder{}
+	encoder.buffer.WriteString(MAGIC)
+	encoder.writeUint16(VERSION)
+
+	encoder.writeUint32(len(bytecode.GlobalNames))
+	for _, name := range bytecode.GlobalNames {
+		encoder.writeString(name)
+	}
+
+	encoder.writeUint32(len(functions))
+	for _, function := range functions {
+		encoder.writeString(function.Name)
+		encoder.writeString(function.Source)
+		encoder.writeUint16(function.NumParameters)
+		encoder.writeUint16(function.NumLocals)
+		encoder.writeUint32(len(function.Instructions))
+		encoder.buffer.Write(function.Instructions)
+	}
+
+	encoder.writeUint32(len(bytecode.Constants))
+	for index, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			encoder.buffer.WriteByte(INTEGER_CONSTANT)
+			encoder.writeUint64(uint64(constant.Value))
+		case *object.Float:
+			encoder.buffer.WriteByte(FLOAT_CONSTANT)
+			encoder.writeUint64(math.Float64bits(constant.Value))
+		case *object.String:
+			encoder.buffer.WriteByte(STRING_CONSTANT)
+			encoder.writeString(constant.Value)
+		case *object.CompiledFunction:
+			encoder.buffer.WriteByte(FUNCTION_CONSTANT)
+			encoder.writeUint32(functionIndexes[constant])
+		case *object.Builtin:
+			if _, ok := evaluator.LookupBuiltin(constant.Name); !ok {
+				return nil, fmt.Errorf("constant %d: builtin %q is not registered", index, constant.Name)
+			}
+			encoder.buffer.WriteByte(BUILTIN_CONSTANT)
+			encoder.writeString(constant.Name)
+		default:
+			return nil, fmt.Errorf("constant %d: cannot encode %s", index, constant.Type())
+		}
+	}
+
+	for _, function := range functions {
+		encoder.writeUint32(len(function.Lines))
+		for _, entry := range function.Lines {
+			encoder.writeUint32(entry.Offset)
+			encoder.writeUint32(entry.Line)
+		}
+	}
+
+	encoder.writeUint32(int(crc32.ChecksumIEEE(encoder.buffer.Bytes())))
+
+	return encoder.buffer.Bytes(), nil
+}
+
+// Decode reads bytecode written by Encode. Builtins are looked up by name in
+// the registry, so a program can only be loaded where its builtins exist.
+// The instructions are verified before they are returned, so that a damaged
+// or hand-made file is an error here rather than a crash in the VM.
+func Decode(data []byte) (*compiler.Bytecode, error) {
+	if !IsBytecode(data) {
+		return nil, ErrNotBytecode
+	}
+	if len(data) < len(MAGIC)+2+4 {
+		return nil, errors.New("unexpected end of file")
+	}
+	if version := binary.BigEndian.Uint16(data[len(MAGIC):]); version != VERSION {
+		return nil, &VersionError{Version: version}
+	}
+
+	body, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, ErrChecksum
+	}
+
+	decoder := &decoder{data: body, offset: len(MAGIC) + 2}
+	bytecode := &compiler.Bytecode{}
+
+	bytecode.GlobalNames = make([]string, decoder.readCount())
+	for index := range bytecode.GlobalNames {
+		bytecode.GlobalNames[index] = decoder.readString()
+	}
+
+	functions := make([]*object.CompiledFunction, decoder.readCount())
+	for index := range functions {
+		functions[index] = &object.CompiledFunction{
+			Name:          decoder.readString(),
+			Source:        decoder.readString(),
+			NumParameters: decoder.readUint16(),
+			NumLocals:     decoder.readUint16(),
+			Instructions:  code.Instructions(decoder.readBytes(decoder.readCount())),
+		}
+	}
+	if decoder.err == nil && len(functions) == 0 {
+		decoder.fail(errors.New("missing main program"))
+	}
+
+	bytecode.Constants = make([]object.Object, decoder.readCount())
+	for index := range bytecode.Constants {
+		bytecode.Constants[index] = decoder.readConstant(functions)
+	}
+
+	for _, function := range functions {
+		function.Lines = make(code.LineTable, decoder.readCount())
+		for index := range function.Lines {
+			function.Lines[index] = code.LineEntry{Offset: decoder.readUint32(), Line: decoder.readUint32()}
+		}
+	}
+
+	if decoder.err == nil && decoder.offset != len(decoder.data) {
+		decoder.fail(fmt.Errorf("%d unexpected bytes at the end of the file", len(decoder.data)-decoder.offset))
+	}
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+
+	if err := verify(functions, bytecode.Constants, len(bytecode.GlobalNames)); err != nil {
+		return nil, err
+	}
+
+	bytecode.Instructions = functions[0].Instructions
+	bytecode.Lines = functions[0].Lines
+
+	return bytecode, nil
+}
+
+type encoder struct {
+	buffer bytes.Buffer
+}
+
+func (encoder *encoder) writeUint16(value int) {
+	encoder.buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(value)))
+}
+
+func (encoder *encoder) writeUint32(value int) {
+	encoder.buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(value)))
+}
+
+func (encoder *encoder) writeUint64(value uint64) {
+	encoder.buffer.Write(binary.BigEndian.AppendUint64(nil, value))
+}
+
+func (encoder *encoder) writeString(value string) {
+	encoder.writeUint32(len(value))
+	encoder.buffer.WriteString(value)
+}
+
+// decoder reads values from data until the first error, after which every
+// read returns a zero value and err keeps that first error.
+type decoder struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (decoder *decoder) fail(err error) {
+	if decoder.err == nil {
+		decoder.err = err
+	}
+}
+
+func (decoder *decoder) readBytes(length int) []byte {
+	if decoder.err != nil {
+		return nil
+	}
+	if length > len(decoder.data)-decoder.offset {
+		decoder.fail(errors.New("unexpected end of file"))
+		return nil
+	}
+
+	value := decoder.data[decoder.offset : decoder.offset+length]
+	decoder.offset += length
+
+	return value
+}
+
+func (decoder *decoder) readUint16() int {
+	value := decoder.readBytes(2)
+	if value == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(value))
+}
+
+func (decoder *decoder) readUint32() int {
+	value := decoder.readBytes(4)
+	if value == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(value))
+}
+
+func (decoder *decoder) readUint64() uint64 {
+	value := decoder.readBytes(8)
+	if value == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+// readCount reads the length of a list, which can never be more than the
+// bytes left, so that a corrupt count cannot make us allocate without bound.
+func (decoder *decoder) readCount() int {
+	count := decoder.readUint32()
+	if count > len(decoder.data)-decoder.offset {
+		decoder.fail(errors.New("unexpected end of file"))
+		return 0
+	}
+	return count
+}
+
+func (decoder *decoder) readString() string {
+	return string(decoder.readBytes(decoder.readCount()))
+}
+
+func (decoder *decoder) readConstant(functions []*object.CompiledFunction) object.Object {
+	tag := decoder.readBytes(1)
+	if tag == nil {
+		return nil
+	}
+
+	switch tag[0] {
+	case INTEGER_CONSTANT:
+		return &object.Integer{Value: int64(decoder.readUint64())}
+	case FLOAT_CONSTANT:
+		return &object.Float{Value: math.Float64frombits(decoder.readUint64())}
+	case STRING_CONSTANT:
+		return &object.String{Value: decoder.readString()}
+	case FUNCTION_CONSTANT:
+		index := decoder.readUint32()
+		if decoder.err != nil {
+			return nil
+		}
+		if index == 0 || index >= len(functions) {
+			decoder.fail(fmt.Errorf("function %d not in the function table", index))
+			return nil
+		}
+		return functions[index]
+	case BUILTIN_CONSTANT:
+		name := decoder.readString()
+		if decoder.err != nil {
+			return nil
+		}
+		builtin, ok := evaluator.LookupBuiltin(name)
+		if !ok {
+			decoder.fail(fmt.Errorf("unknown builtin %q", name))
+			return nil
+		}
+		return builtin
+	default:
+		decoder.fail(fmt.Errorf("unknown constant tag %d", tag[0]))
+		return nil
+	}
+}
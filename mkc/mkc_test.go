@@ -0,0 +1,245 @@
+package mkc
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/object"
+	"github.com/Favot/monkey-interpreter/parser"
+	"github.com/Favot/monkey-interpreter/vm"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"1.25 * 2", "2.5"},
+		{`"mon" + "key"`, "monkey"},
+		{`len([1, 2, 3]) + len("ab")`, "5"},
+		{"let adder = fn(a) { fn(b) { a + b } }; adder(40)(2)", "42"},
+		{"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15)", "610"},
+		{`{"a": [true, false], 2: "b"}["a"][0]`, "true"},
+		{"let n = 1; fn(x) { x + n }", "fn(x) {\n(x + n)\n}"},
+	}
+
+	for _, tt := range tests {
+		original := compile(t, tt.input)
+
+		data, err := Encode(original)
+		if err != nil {
+			t.Fatalf("input %s: Encode failed: %s", tt.input, err)
+		}
+
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Fatalf("input %s: Decode failed: %s", tt.input, err)
+		}
+
+		if disassemble(t, decoded) != disassemble(t, original) {
+			t.Errorf("input %s: disassembly changed.\nwant=\n%s\ngot=\n%s", tt.input, disassemble(t, original), disassemble(t, decoded))
+		}
+
+		machine := vm.New(decoded)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("input %s: vm error: %s", tt.input, err)
+		}
+
+		if result := machine.LastPoppedStackElement().Inspect(); result != tt.expected {
+			t.Errorf("input %s: want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, err := Encode(compile(t, `let f = fn(x) { x + "!" }; f("a")`))
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err)
+	}
+
+	var versionError *VersionError
+	newer := withChecksum(append([]byte{}, data...))
+	binary.BigEndian.PutUint16(newer[len(MAGIC):], VERSION+1)
+	if _, err := Decode(newer); !errors.As(err, &versionError) || versionError.Version != VERSION+1 {
+		t.Errorf("expected a VersionError, got=%v", err)
+	} else if !strings.Contains(err.Error(), "rebuild the script from source") {
+		t.Errorf("version error message wrong. got=%q", err)
+	}
+
+	if _, err := Decode([]byte("let x = 1;")); err != ErrNotBytecode {
+		t.Errorf("expected ErrNotBytecode, got=%v", err)
+	}
+
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := Decode(corrupt); err != ErrChecksum {
+		t.Errorf("expected ErrChecksum, got=%v", err)
+	}
+
+	truncated := withChecksum(append([]byte{}, data[:len(data)-12]...))
+	if _, err := Decode(truncated); err == nil || err.Error() != "unexpected end of file" {
+		t.Errorf("expected unexpected end of file, got=%v", err)
+	}
+
+	if _, err := Decode([]byte(MAGIC)); err == nil || err.Error() != "unexpected end of file" {
+		t.Errorf("expected unexpected end of file for a bare header, got=%v", err)
+	}
+}
+
+func TestDecodeVerifies(t *testing.T) {
+	function := func(numParameters, numLocals int, instructions ...[]byte) *object.CompiledFunction {
+		return &object.CompiledFunction{
+			Instructions:  concat(instructions...),
+			NumParameters: numParameters,
+			NumLocals:     numLocals,
+		}
+	}
+	identity := function(1, 1, code.Make(code.OpGetLocal, 0), code.Make(code.OpReturnValue))
+	integers := []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}
+
+	tests := []struct {
+		instructions []byte
+		constants    []object.Object
+		expected     string
+	}{
+		{
+			concat(code.Make(code.OpConstant, 80), code.Make(code.OpPop)),
+			integers,
+			"function 0: 0000 OpConstant constant=80: there are only 2 constants",
+		},
+		{
+			concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpConstant, 1), code.Make(code.OpCall, 9)),
+			[]object.Object{identity, &object.Integer{Value: 1}},
+			"function 0: 0007 OpCall arguments=9: needs 10 values but the stack has 2",
+		},
+		{
+			concat(code.Make(code.OpConstant, 0))[:2],
+			integers,
+			"function 0: 0000 OpConstant is cut off",
+		},
+		{
+			[]byte{255},
+			nil,
+			"function 0: 0000 opcode 255 undefined",
+		},
+		{
+			concat(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 2), code.Make(code.OpNull), code.Make(code.OpPop)),
+			nil,
+			"function 0: 0001 OpJumpNotTruthy target=2: 0002 is not the start of an instruction",
+		},
+		{
+			concat(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 5), code.Make(code.OpNull), code.Make(code.OpPop)),
+			nil,
+			"function 0: 0004 OpNull: 0005 is reached with 0 and with 1 values on the stack",
+		},
+		{
+			code.Make(code.OpPop),
+			nil,
+			"function 0: 0000 OpPop: needs 1 values but the stack has 0",
+		},
+		{
+			concat(code.Make(code.OpGetGlobal, 1), code.Make(code.OpPop)),
+			nil,
+			"function 0: 0000 OpGetGlobal global=1: there are only 1 globals",
+		},
+		{
+			concat(code.Make(code.OpGetLocal, 0), code.Make(code.OpPop)),
+			nil,
+			"function 0: 0000 OpGetLocal local=0: the function has only 0 locals",
+		},
+		{
+			concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)),
+			[]object.Object{function(0, 0, code.Make(code.OpGetFree, 0), code.Make(code.OpReturnValue))},
+			"function 0: 0000 OpClosure constant=0 free=0: the function uses 1 free variables",
+		},
+		{
+			concat(code.Make(code.OpConstant, 0), code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)),
+			integers,
+			"function 0: 0003 OpClosure constant=0 free=0: constant 0 is not a function",
+		},
+		{
+			concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)),
+			[]object.Object{function(0, 0, code.Make(code.OpConstant, 0), code.Make(code.OpPop))},
+			"function 1: 0003 OpPop: function ends without returning",
+		},
+		{
+			concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)),
+			[]object.Object{function(2, 1, code.Make(code.OpReturn))},
+			"function 1: 2 parameters but only 1 locals",
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := Encode(&compiler.Bytecode{Instructions: tt.instructions, Constants: tt.constants, GlobalNames: []string{"x"}})
+		if err != nil {
+			t.Fatalf("Encode failed: %s", err)
+		}
+
+		if _, err := Decode(data); err == nil || err.Error() != tt.expected {
+			t.Errorf("Decode error wrong. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	bytecode := compile(t, "1")
+	bytecode.Constants = append(bytecode.Constants, &object.Array{})
+
+	if _, err := Encode(bytecode); err == nil || err.Error() != "constant 1: cannot encode ARRAY" {
+		t.Errorf("expected an encode error, got=%v", err)
+	}
+
+	bytecode.Constants[1] = &object.Builtin{Name: "host"}
+	if _, err := Encode(bytecode); err == nil || err.Error() != `constant 1: builtin "host" is not registered` {
+		t.Errorf("expected an unregistered builtin error, got=%v", err)
+	}
+}
+
+func concat(instructions ...[]byte) []byte {
+	out := []byte{}
+	for _, instruction := range instructions {
+		out = append(out, instruction...)
+	}
+	return out
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	program := parser.NewParser(lexer.NewLexer(input)).ParseProgram()
+
+	compiler := compiler.New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("input %s: compiler error: %s", input, err)
+	}
+
+	return compiler.Bytecode()
+}
+
+func disassemble(t *testing.T, bytecode *compiler.Bytecode) string {
+	t.Helper()
+
+	var out strings.Builder
+	if err := bytecode.Disassemble(&out); err != nil {
+		t.Fatalf("Disassemble failed: %s", err)
+	}
+	return out.String()
+}
+
+// withChecksum replaces the checksum at the end of data with that of a
+// truncated or edited body.
+func withChecksum(data []byte) []byte {
+	body := data[:len(data)-4]
+	encoder := &encoder{}
+	encoder.buffer.Write(body)
+	encoder.writeUint32(int(crc32.ChecksumIEEE(body)))
+	return encoder.buffer.Bytes()
+}
@@ -0,0 +1,286 @@
+package mkc
+
+import (
+	"fmt"
+
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+// verify checks that the decoded functions can run without the VM reading
+// outside its stack, constants, globals, locals or free variables. The
+// checksum only guards against accidental damage, so everything the VM
+// trusts is checked here: every opcode is known and its operands are all
+// there, every index is in range, every jump lands on an instruction and
+// the stack holds the same number of values whichever way an instruction
+// is reached. functions[0] is the main program.
+func verify(functions []*object.CompiledFunction, constants []object.Object, numGlobals int) error {
+	numFree := map[*object.CompiledFunction]int{}
+	instructions := make([][]instruction, len(functions))
+
+	for index, function := range functions {
+		if function.NumParameters > function.NumLocals {
+			return fmt.Errorf("function %d: %d parameters but only %d locals", index, function.NumParameters, function.NumLocals)
+		}
+
+		decoded, err := decodeInstructions(function.Instructions)
+		if err != nil {
+			return fmt.Errorf("function %d: %s", index, err)
+		}
+		instructions[index] = decoded
+
+		for _, instruction := range decoded {
+			if instruction.op == code.OpGetFree || instruction.op == code.OpCaptureFree {
+				numFree[function] = max(numFree[function], instruction.operands[0]+1)
+			}
+		}
+	}
+
+	if numFree[functions[0]] > 0 {
+		return fmt.Errorf("function 0: the main program has no free variables")
+	}
+
+	for index, function := range functions {
+		verifier := &verifier{
+			function:     function,
+			main:         index == 0,
+			instructions: instructions[index],
+			constants:    constants,
+			numGlobals:   numGlobals,
+			numFree:      numFree,
+		}
+		if err := verifier.verify(); err != nil {
+			return fmt.Errorf("function %d: %s", index, err)
+		}
+	}
+
+	return nil
+}
+
+type instruction struct {
+	offset     int
+	op         code.Opcode
+	definition *code.Definition
+	operands   []int
+}
+
+func (instruction instruction) String() string {
+	return fmt.Sprintf("%04d %s", instruction.offset, code.FormatOperands(instruction.definition, instruction.operands))
+}
+
+// decodeInstructions splits a function's instructions, checking that every
+// opcode is known and that no operand runs past the end.
+func decodeInstructions(instructions code.Instructions) ([]instruction, error) {
+	decoded := []instruction{}
+
+	for offset := 0; offset < len(instructions); {
+		definition, err := code.Lookup(instructions[offset])
+		if err != nil {
+			return nil, fmt.Errorf("%04d %s", offset, err)
+		}
+
+		width := 0
+		for _, operandWidth := range definition.OperandWidths {
+			width += operandWidth
+		}
+		if offset+1+width > len(instructions) {
+			return nil, fmt.Errorf("%04d %s is cut off", offset, definition.Name)
+		}
+
+		operands, read := code.ReadOperands(definition, instructions[offset+1:])
+		decoded = append(decoded, instruction{offset, code.Opcode(instructions[offset]), definition, operands})
+		offset += 1 + read
+	}
+
+	return decoded, nil
+}
+
+type verifier struct {
+	function     *object.CompiledFunction
+	main         bool
+	instructions []instruction
+	constants    []object.Object
+	numGlobals   int
+	numFree      map[*object.CompiledFunction]int
+
+	// heights gives the stack height on entry to each instruction, indexed
+	// like instructions, or -1 while the instruction has not been reached.
+	heights []int
+	pending []int
+}
+
+// verify follows every path through the function from its first
+// instruction.
+func (verifier *verifier) verify() error {
+	verifier.heights = make([]int, len(verifier.instructions))
+	for index := range verifier.heights {
+		verifier.heights[index] = -1
+	}
+
+	if err := verifier.reach(0, 0); err != nil {
+		return err
+	}
+
+	for len(verifier.pending) > 0 {
+		index := verifier.pending[len(verifier.pending)-1]
+		verifier.pending = verifier.pending[:len(verifier.pending)-1]
+
+		if err := verifier.step(index); err != nil {
+			return fmt.Errorf("%s: %s", verifier.instructions[index], err)
+		}
+	}
+
+	return nil
+}
+
+// reach records that the instruction at offset is entered with height
+// values on the stack. An offset just past the last instruction is the end
+// of the main program.
+func (verifier *verifier) reach(offset, height int) error {
+	if offset == len(verifier.function.Instructions) {
+		if !verifier.main {
+			return fmt.Errorf("function ends without returning")
+		}
+		return nil
+	}
+
+	index := verifier.indexOf(offset)
+	if index < 0 {
+		return fmt.Errorf("%04d is not the start of an instruction", offset)
+	}
+
+	switch verifier.heights[index] {
+	case -1:
+		verifier.heights[index] = height
+		verifier.pending = append(verifier.pending, index)
+	case height:
+	default:
+		return fmt.Errorf("%04d is reached with %d and with %d values on the stack", offset, verifier.heights[index], height)
+	}
+
+	return nil
+}
+
+func (verifier *verifier) indexOf(offset int) int {
+	low, high := 0, len(verifier.instructions)
+	for low < high {
+		middle := (low + high) / 2
+		if verifier.instructions[middle].offset < offset {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+
+	if low < len(verifier.instructions) && verifier.instructions[low].offset == offset {
+		return low
+	}
+	return -1
+}
+
+// step checks one instruction and passes the stack height on to the
+// instructions that can run after it.
+func (verifier *verifier) step(index int) error {
+	instruction := verifier.instructions[index]
+	height := verifier.heights[index]
+
+	next := len(verifier.function.Instructions)
+	if index+1 < len(verifier.instructions) {
+		next = verifier.instructions[index+1].offset
+	}
+
+	pops, pushes := 0, 0
+	operand := 0
+	if len(instruction.operands) > 0 {
+		operand = instruction.operands[0]
+	}
+
+	switch instruction.op {
+	case code.OpConstant:
+		if operand >= len(verifier.constants) {
+			return fmt.Errorf("there are only %d constants", len(verifier.constants))
+		}
+		pushes = 1
+
+	case code.OpPop, code.OpSetGlobal, code.OpSetLocal:
+		pops = 1
+
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+		code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual,
+		code.OpIndex:
+		pops, pushes = 2, 1
+
+	case code.OpMinus, code.OpBang:
+		pops, pushes = 1, 1
+
+	case code.OpTrue, code.OpFalse, code.OpNull, code.OpGetGlobal, code.OpGetLocal,
+		code.OpGetFree, code.OpCaptureLocal, code.OpCaptureFree:
+		pushes = 1
+
+	case code.OpJump, code.OpJumpNotTruthy, code.OpJumpTruthy:
+		if instruction.op != code.OpJump {
+			pops = 1
+		}
+
+	case code.OpArray:
+		pops, pushes = operand, 1
+
+	case code.OpHash:
+		if operand%2 != 0 {
+			return fmt.Errorf("a hash needs an even number of elements")
+		}
+		pops, pushes = operand, 1
+
+	case code.OpCall:
+		pops, pushes = operand+1, 1
+
+	case code.OpReturnValue:
+		pops = 1
+
+	case code.OpClosure:
+		function, ok := verifier.constantAt(operand).(*object.CompiledFunction)
+		if !ok {
+			return fmt.Errorf("constant %d is not a function", operand)
+		}
+		if free := instruction.operands[1]; free < verifier.numFree[function] {
+			return fmt.Errorf("the function uses %d free variables", verifier.numFree[function])
+		}
+		pops, pushes = instruction.operands[1], 1
+	}
+
+	switch instruction.op {
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if operand >= verifier.numGlobals {
+			return fmt.Errorf("there are only %d globals", verifier.numGlobals)
+		}
+	case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+		if operand >= verifier.function.NumLocals {
+			return fmt.Errorf("the function has only %d locals", verifier.function.NumLocals)
+		}
+	}
+
+	if height < pops {
+		return fmt.Errorf("needs %d values but the stack has %d", pops, height)
+	}
+	height += pushes - pops
+
+	switch instruction.op {
+	case code.OpReturnValue, code.OpReturn:
+		return nil
+	case code.OpJump:
+		return verifier.reach(operand, height)
+	case code.OpJumpNotTruthy, code.OpJumpTruthy:
+		if err := verifier.reach(operand, height); err != nil {
+			return err
+		}
+	}
+
+	return verifier.reach(next, height)
+}
+
+func (verifier *verifier) constantAt(index int) object.Object {
+	if index >= len(verifier.constants) {
+		return nil
+	}
+	return verifier.constants[index]
+}
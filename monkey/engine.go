@@ -0,0 +1,204 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/object"
+	"github.com/Favot/monkey-interpreter/vm"
+)
+
+// Engine selects how an Interpreter runs programs. Both engines give the
+// same results and error messages.
+type Engine string
+
+const (
+	// ENGINE_EVAL walks the syntax tree.
+	ENGINE_EVAL Engine = "eval"
+	// ENGINE_VM compiles programs to bytecode and runs them on a virtual
+	// machine.
+	ENGINE_VM Engine = "vm"
+)
+
+// engine holds the globals of an Interpreter and runs programs against them.
+// Errors a program raises are returned as *RuntimeError. Engines that cannot
+// trace ignore trace.
+type engine interface {
+	run(ctx context.Context, program *abstractSyntaxTree.Program, trace io.Writer) (object.Object, error)
+	set(name string, obj object.Object)
+	get(name string) (object.Object, bool)
+	call(function object.Object, arguments []object.Object) (object.Object, error)
+}
+
+func newEngine(kind Engine, prelude map[string]object.Object) (engine, error) {
+	var engine engine
+
+	switch kind {
+	case ENGINE_EVAL:
+		engine = newEvalEngine()
+	case ENGINE_VM:
+		engine = newVmEngine()
+	default:
+		return nil, fmt.Errorf("unknown engine %q", kind)
+	}
+
+	for name, obj := range prelude {
+		engine.set(name, obj)
+	}
+
+	return engine, nil
+}
+
+type evalEngine struct {
+	environment *object.Environment
+}
+
+func newEvalEngine() *evalEngine {
+	return &evalEngine{environment: object.NewEnvironment()}
+}
+
+func (engine *evalEngine) run(ctx context.Context, program *abstractSyntaxTree.Program, trace io.Writer) (object.Object, error) {
+	engine.environment.SetContext(ctx)
+	defer engine.environment.SetContext(nil)
+
+	evaluated := evaluator.Eval(program, engine.environment)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return runtimeResult(evaluated)
+}
+
+func (engine *evalEngine) set(name string, obj object.Object) {
+	engine.environment.Set(name, obj)
+}
+
+func (engine *evalEngine) get(name string) (object.Object, bool) {
+	return engine.environment.Get(name)
+}
+
+func (engine *evalEngine) call(function object.Object, arguments []object.Object) (object.Object, error) {
+	return runtimeResult(evaluator.ApplyFunction(function, arguments))
+}
+
+// vmEngine keeps the symbol table, constant pool and globals between
+// programs, so each one is compiled on top of the ones before it.
+type vmEngine struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+func newVmEngine() *vmEngine {
+	return &vmEngine{
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GLOBALS_SIZE),
+	}
+}
+
+func (engine *vmEngine) run(ctx context.Context, program *abstractSyntaxTree.Program, trace io.Writer) (object.Object, error) {
+	bytecode, err := engine.compile(program)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.execute(ctx, bytecode, trace)
+}
+
+func (engine *vmEngine) execute(ctx context.Context, bytecode *compiler.Bytecode, trace io.Writer) (object.Object, error) {
+	machine := vm.NewWithGlobalsStore(bytecode, engine.globals)
+	machine.Trace = trace
+
+	if err := machine.RunContext(ctx); err != nil {
+		return nil, vmError(ctx, err)
+	}
+
+	return machine.LastPoppedStackElement(), nil
+}
+
+// load prepares the engine to run bytecode compiled elsewhere. The globals
+// the engine already has must sit in the slots the bytecode expects them in,
+// and the engine must not have compiled anything, since the bytecode brings
+// its own constant pool.
+func (engine *vmEngine) load(bytecode *compiler.Bytecode) error {
+	if len(engine.constants) > 0 {
+		return errors.New("bytecode can only be loaded before anything is compiled")
+	}
+
+	globalNames := engine.symbolTable.GlobalNames()
+	for index, name := range bytecode.GlobalNames {
+		if index < len(globalNames) && globalNames[index] != name {
+			return fmt.Errorf("bytecode expects global %d to be %s, but it is %s", index, name, globalNames[index])
+		}
+		if symbol := engine.symbolTable.Define(name); symbol.Index != index {
+			return fmt.Errorf("bytecode expects global %d to be %s, but %s is global %d", index, name, name, symbol.Index)
+		}
+	}
+
+	engine.constants = bytecode.Constants
+
+	return nil
+}
+
+// compile compiles program on top of the programs compiled before it.
+func (engine *vmEngine) compile(program *abstractSyntaxTree.Program) (*compiler.Bytecode, error) {
+	compiler := compiler.NewWithState(engine.symbolTable, engine.constants)
+
+	err := compiler.Compile(program)
+	// The symbol table may refer to constants added before the error, so
+	// they are kept either way.
+	engine.constants = compiler.Bytecode().Constants
+	if err != nil {
+		return nil, &RuntimeError{Message: err.Error()}
+	}
+
+	return compiler.Bytecode(), nil
+}
+
+func (engine *vmEngine) set(name string, obj object.Object) {
+	symbol := engine.symbolTable.Define(name)
+	engine.globals[symbol.Index] = obj
+}
+
+func (engine *vmEngine) get(name string) (object.Object, bool) {
+	symbol, ok := engine.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GLOBAL_SCOPE || engine.globals[symbol.Index] == nil {
+		return nil, false
+	}
+	return engine.globals[symbol.Index], true
+}
+
+func (engine *vmEngine) call(function object.Object, arguments []object.Object) (object.Object, error) {
+	bytecode := &compiler.Bytecode{
+		Constants:   engine.constants,
+		GlobalNames: engine.symbolTable.GlobalNames(),
+	}
+
+	result, err := vm.NewWithGlobalsStore(bytecode, engine.globals).Call(function, arguments)
+	if err != nil {
+		return nil, vmError(context.Background(), err)
+	}
+	return result, nil
+}
+
+// vmError returns ctx.Err() if err stopped a cancelled program, and err as a
+// *RuntimeError otherwise.
+func vmError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return &RuntimeError{Message: err.Error()}
+}
+
+func runtimeResult(evaluated object.Object) (object.Object, error) {
+	if errorObject, ok := evaluated.(*object.Error); ok {
+		return nil, &RuntimeError{Message: errorObject.Message}
+	}
+	return evaluated, nil
+}
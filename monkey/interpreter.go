@@ -0,0 +1,173 @@
+// Package monkey embeds the Monkey interpreter in Go programs.
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/object"
+	"github.com/Favot/monkey-interpreter/parser"
+)
+
+// Interpreter owns globals that persist across calls, so a script run first
+// can define functions that later calls use. An Interpreter is not safe for
+// concurrent use.
+type Interpreter struct {
+	// Stdout receives what scripts print with puts.
+	Stdout io.Writer
+	// Trace, when set, receives a line for every instruction ENGINE_VM
+	// executes. ENGINE_EVAL ignores it.
+	Trace io.Writer
+
+	engine engine
+}
+
+// RuntimeError is returned when a script evaluates to a Monkey error.
+type RuntimeError struct {
+	Message string
+}
+
+func (runtimeError *RuntimeError) Error() string { return runtimeError.Message }
+
+// NewInterpreter returns an Interpreter that uses ENGINE_EVAL.
+func NewInterpreter() *Interpreter {
+	interpreter, _ := NewInterpreterWithEngine(ENGINE_EVAL)
+	return interpreter
+}
+
+func NewInterpreterWithEngine(kind Engine) (*Interpreter, error) {
+	interpreter := &Interpreter{Stdout: os.Stdout}
+
+	engine, err := newEngine(kind, map[string]object.Object{
+		"puts": &object.Builtin{Name: "puts", Function: interpreter.puts},
+	})
+	if err != nil {
+		return nil, err
+	}
+	interpreter.engine = engine
+
+	return interpreter, nil
+}
+
+// Run evaluates src in the global environment. It stops early and returns
+// ctx.Err() once ctx is cancelled.
+func (interpreter *Interpreter) Run(ctx context.Context, src string) error {
+	return interpreter.RunSource(ctx, "", src)
+}
+
+// RunSource is like Run but reports syntax errors against filename.
+func (interpreter *Interpreter) RunSource(ctx context.Context, filename string, src string) error {
+	_, err := interpreter.evaluate(ctx, filename, src)
+	return err
+}
+
+// Eval evaluates src in the global environment and returns the value of its
+// last statement.
+func (interpreter *Interpreter) Eval(src string) (Value, error) {
+	return interpreter.evaluate(context.Background(), "", src)
+}
+
+// Set binds name to goValue, converted as described on ToObject.
+func (interpreter *Interpreter) Set(name string, goValue interface{}) error {
+	obj, err := ToObject(goValue)
+	if err != nil {
+		return fmt.Errorf("set %s: %w", name, err)
+	}
+
+	interpreter.engine.set(name, obj)
+	return nil
+}
+
+// Get returns the global bound to name.
+func (interpreter *Interpreter) Get(name string) (Value, bool) {
+	obj, ok := interpreter.engine.get(name)
+	if !ok {
+		return Value{}, false
+	}
+	return Value{object: obj}, true
+}
+
+// Call calls the function bound to fnName with args converted to Monkey
+// values.
+func (interpreter *Interpreter) Call(fnName string, args ...interface{}) (Value, error) {
+	function, ok := interpreter.engine.get(fnName)
+	if !ok {
+		if builtin, isBuiltin := evaluator.LookupBuiltin(fnName); isBuiltin {
+			function, ok = builtin, true
+		}
+	}
+	if !ok {
+		return Value{}, fmt.Errorf("call %s: function not found", fnName)
+	}
+
+	arguments := make([]object.Object, len(args))
+	for index, arg := range args {
+		argument, err := ToObject(arg)
+		if err != nil {
+			return Value{}, fmt.Errorf("call %s: argument %d: %w", fnName, index+1, err)
+		}
+		arguments[index] = argument
+	}
+
+	return value(interpreter.engine.call(function, arguments))
+}
+
+// Compile compiles src to bytecode without running it. Globals it defines
+// take the next free slots, as if the program was about to be run, so the
+// bytecode runs against this Interpreter's globals. Only ENGINE_VM compiles.
+func (interpreter *Interpreter) Compile(filename string, src string) (*compiler.Bytecode, error) {
+	engine, ok := interpreter.engine.(*vmEngine)
+	if !ok {
+		return nil, fmt.Errorf("compile %s: only the %s engine compiles to bytecode", filename, ENGINE_VM)
+	}
+
+	program, err := parser.ParseSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.compile(program)
+}
+
+// RunBytecode runs bytecode returned by Compile, possibly on another
+// Interpreter that was set up the same way. Only ENGINE_VM runs bytecode.
+func (interpreter *Interpreter) RunBytecode(ctx context.Context, bytecode *compiler.Bytecode) error {
+	engine, ok := interpreter.engine.(*vmEngine)
+	if !ok {
+		return fmt.Errorf("only the %s engine runs bytecode", ENGINE_VM)
+	}
+
+	if err := engine.load(bytecode); err != nil {
+		return err
+	}
+
+	_, err := engine.execute(ctx, bytecode, interpreter.Trace)
+	return err
+}
+
+func (interpreter *Interpreter) evaluate(ctx context.Context, filename string, src string) (Value, error) {
+	program, err := parser.ParseSource(filename, src)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return value(interpreter.engine.run(ctx, program, interpreter.Trace))
+}
+
+func (interpreter *Interpreter) puts(arguments ...object.Object) object.Object {
+	for _, argument := range arguments {
+		fmt.Fprintln(interpreter.Stdout, argument.Inspect())
+	}
+	return nil
+}
+
+func value(obj object.Object, err error) (Value, error) {
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{object: obj}, nil
+}
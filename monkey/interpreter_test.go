@@ -0,0 +1,415 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/diagnostic"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{"1.5 * 2", 3.0},
+		{`"mon" + "key"`, "monkey"},
+		{"1 < 2", true},
+		{"if (false) { 1 }", nil},
+		{"let x = 1;", nil},
+		{`[1, "a", [false]]`, []interface{}{int64(1), "a", []interface{}{false}}},
+		{`{"a": 1, 2: "b"}`, map[interface{}]interface{}{"a": int64(1), int64(2): "b"}},
+	}
+
+	for _, tt := range tests {
+		interpreter := NewInterpreter()
+		value, err := interpreter.Eval(tt.input)
+		if err != nil {
+			t.Errorf("input %s: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if actual := value.Interface(); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("input %s: expected=%#v, got=%#v", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	_, err := interpreter.Eval("let x = ;")
+	var errorList diagnostic.ErrorList
+	if !errors.As(err, &errorList) || len(errorList) != 1 {
+		t.Errorf("parse error wrong. got=%#v", err)
+	}
+
+	_, err = interpreter.Eval("1 + true")
+	var runtimeError *RuntimeError
+	if !errors.As(err, &runtimeError) || runtimeError.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("runtime error wrong. got=%#v", err)
+	}
+}
+
+func TestGlobalsPersist(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	if err := interpreter.Run(context.Background(), "let add = fn(a, b) { a + b }; let total = add(1, 2);"); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	total, ok := interpreter.Get("total")
+	if !ok || total.Interface() != int64(3) {
+		t.Errorf("total wrong. got=%v, %t", total, ok)
+	}
+
+	if _, ok := interpreter.Get("missing"); ok {
+		t.Errorf("Get(missing) found a value")
+	}
+
+	value, err := interpreter.Call("add", 40, 2)
+	if err != nil || value.Interface() != int64(42) {
+		t.Errorf("Call(add) wrong. got=%v, %v", value, err)
+	}
+
+	value, err = interpreter.Call("len", []string{"a", "b"})
+	if err != nil || value.Interface() != int64(2) {
+		t.Errorf("Call(len) wrong. got=%v, %v", value, err)
+	}
+
+	if _, err := interpreter.Call("missing"); err == nil || err.Error() != "call missing: function not found" {
+		t.Errorf("Call(missing) wrong. got=%v", err)
+	}
+
+	if _, err := interpreter.Call("add", 1); err == nil || err.Error() != "wrong number of arguments: want=2, got=1" {
+		t.Errorf("Call(add, 1) wrong. got=%v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	settings := map[string]interface{}{
+		"name":    "service",
+		"retries": uint8(3),
+		"ratio":   float32(0.5),
+		"tags":    []string{"a", "b"},
+		"enabled": true,
+		"parent":  nil,
+	}
+
+	for name, value := range settings {
+		if err := interpreter.Set(name, value); err != nil {
+			t.Fatalf("Set(%s) failed: %s", name, err)
+		}
+	}
+
+	value, err := interpreter.Eval(`[name, retries * 2, ratio, tags[1], enabled, parent]`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if value.String() != "[service, 6, 0.5, b, true, null]" {
+		t.Errorf("value wrong. got=%s", value)
+	}
+
+	if err := interpreter.Set("channel", make(chan int)); err == nil || err.Error() != "set channel: cannot convert chan int to a Monkey value" {
+		t.Errorf("Set(channel) wrong. got=%v", err)
+	}
+	if err := interpreter.Set("big", uint64(1<<63)); err == nil {
+		t.Errorf("Set(big) expected an error")
+	}
+	if err := interpreter.Set("bad", map[float64]int{1.5: 1}); err == nil || err.Error() != "set bad: unusable as hash key: FLOAT" {
+		t.Errorf("Set(bad) wrong. got=%v", err)
+	}
+}
+
+func TestSetFunction(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	functions := map[string]interface{}{
+		"repeat": strings.Repeat,
+		"sum": func(numbers ...int) int {
+			total := 0
+			for _, number := range numbers {
+				total += number
+			}
+			return total
+		},
+		"divide": func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("cannot divide %g by zero", a)
+			}
+			return a / b, nil
+		},
+		"keys": func(hash map[string]int) []string {
+			keys := []string{}
+			for key := range hash {
+				keys = append(keys, key)
+			}
+			return keys
+		},
+		"typeOf":  func(value object.Object) string { return string(value.Type()) },
+		"nothing": func() {},
+	}
+
+	for name, function := range functions {
+		if err := interpreter.Set(name, function); err != nil {
+			t.Fatalf("Set(%s) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`repeat("ab", 2)`, "abab", ""},
+		{`sum()`, "0", ""},
+		{`sum(1, 2, 3)`, "6", ""},
+		{`divide(1, 4)`, "0.25", ""},
+		{`divide(1, 0)`, "", "cannot divide 1 by zero"},
+		{`keys({"a": 1})`, "[a]", ""},
+		{`typeOf(fn() {})`, "FUNCTION", ""},
+		{`nothing()`, "null", ""},
+		{`repeat("ab")`, "", "wrong number of arguments: want=2, got=1"},
+		{`repeat(2, 2)`, "", "argument 1: cannot use INTEGER as string"},
+		{`keys({"a": "b"})`, "", "argument 1: cannot use STRING as int"},
+		{`sum(1, 2.5)`, "", "argument 2: cannot use FLOAT as int"},
+	}
+
+	for _, tt := range tests {
+		value, err := interpreter.Eval(tt.input)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("input %s: expected error %q, got=%v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("input %s: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if value.String() != tt.expected {
+			t.Errorf("input %s: expected=%q, got=%q", tt.input, tt.expected, value.String())
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+
+	interpreter := NewInterpreter()
+	interpreter.Stdout = &out
+
+	if err := interpreter.Run(context.Background(), `puts("hello", 1 + 1)`); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	if out.String() != "hello\n2\n" {
+		t.Errorf("output wrong. got=%q", out.String())
+	}
+}
+
+func TestRunCancellation(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := interpreter.Run(ctx, "let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(10);")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run error wrong. got=%v", err)
+	}
+
+	value, err := interpreter.Call("count", 10)
+	if err != nil || value.Interface() != int64(0) {
+		t.Errorf("Call after cancelled Run wrong. got=%v, %v", value, err)
+	}
+}
+
+func TestEngines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{"let add = fn(a, b) { a + b }; add(1, 2)", "3", ""},
+		{"let x = 1;", "null", ""},
+		{"", "null", ""},
+		{"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)", "55", ""},
+		{`let h = {"a": [1, 2]}; h["a"][1] * 2.5`, "5.0", ""},
+		{"return 1; 2", "1", ""},
+		{"let adder = fn(a) { fn(b) { a + b } }; let addTwo = adder(2); addTwo(40)", "42", ""},
+		{"let f = fn() { let loop = fn(n) { if (n == 0) { return \"done\"; } loop(n - 1) }; loop(50) }; f()", "done", ""},
+		{"1 + true", "", "type mismatch: INTEGER + BOOLEAN"},
+		{"missing", "", "identifier not found: missing"},
+		{"let f = fn() { g }; f(); let g = 1;", "", "identifier not found: g"},
+		{"let x = 5; let y = fn() { nope }; x", "5", ""},
+		{"let f = fn() { nope }; f()", "", "identifier not found: nope"},
+		{"fn(x) { x + 1 }", "fn(x) {\n(x + 1)\n}", ""},
+		{"{fn() { 1 }: 2}", "", "unusable as hash key: FUNCTION"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(16382)", "0", ""},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(16383)", "", "stack overflow: more than 16384 nested calls"},
+		{"let f = fn(n) { f(n + 1) }; f(0)", "", "stack overflow: more than 16384 nested calls"},
+		{"let f = fn(x) { let g = fn() { x }; let x = 5; g() }; f(1)", "5", ""},
+		{"let f = fn() { let loop = fn(n) { if (n == 0) { return 0; } loop(n - 1) }; let g = loop; let loop = fn(n) { 42 }; g(3) }; f()", "42", ""},
+		{"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; let g = fib; let fib = 0; g(10)", "", "not a function: INTEGER"},
+	}
+
+	for _, engine := range []Engine{ENGINE_EVAL, ENGINE_VM} {
+		for _, tt := range tests {
+			interpreter, err := NewInterpreterWithEngine(engine)
+			if err != nil {
+				t.Fatalf("NewInterpreterWithEngine(%s) failed: %s", engine, err)
+			}
+
+			value, err := interpreter.Eval(tt.input)
+			if tt.err != "" {
+				var runtimeError *RuntimeError
+				if !errors.As(err, &runtimeError) || runtimeError.Message != tt.err {
+					t.Errorf("%s: input %s: expected error %q, got=%v", engine, tt.input, tt.err, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: input %s: unexpected error: %s", engine, tt.input, err)
+				continue
+			}
+			if value.String() != tt.expected {
+				t.Errorf("%s: input %s: expected=%q, got=%q", engine, tt.input, tt.expected, value.String())
+			}
+		}
+	}
+
+	for _, engine := range []Engine{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+		interpreter, _ := NewInterpreterWithEngine(engine)
+		interpreter.Stdout = &out
+
+		if err := interpreter.Run(context.Background(), "let f = fn() { helper(2) };"); err != nil {
+			t.Fatalf("%s: Run failed: %s", engine, err)
+		}
+		interpreter.Set("helper", func(n int) int { return n * 10 })
+		if value, err := interpreter.Call("f"); err != nil || value.Interface() != int64(20) {
+			t.Errorf("%s: Call(f) wrong. got=%v, %v", engine, value, err)
+		}
+
+		if err := interpreter.Run(context.Background(), "puts(1); let a = fn() { zz };"); err != nil || out.String() != "1\n" {
+			t.Errorf("%s: Run wrong. got=%q, %v", engine, out.String(), err)
+		}
+	}
+
+	if _, err := NewInterpreterWithEngine("jit"); err == nil || err.Error() != `unknown engine "jit"` {
+		t.Errorf("unknown engine error wrong. got=%v", err)
+	}
+}
+
+func TestVmEngineGlobals(t *testing.T) {
+	var out bytes.Buffer
+
+	interpreter, _ := NewInterpreterWithEngine(ENGINE_VM)
+	interpreter.Stdout = &out
+
+	if err := interpreter.Set("greeting", "hello"); err != nil {
+		t.Fatalf("Set failed: %s", err)
+	}
+	if err := interpreter.Set("double", func(n int) int { return n * 2 }); err != nil {
+		t.Fatalf("Set failed: %s", err)
+	}
+
+	err := interpreter.Run(context.Background(), `let shout = fn(s) { puts(s + "!"); double(len(s)) };`)
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	value, err := interpreter.Eval("shout(greeting)")
+	if err != nil || value.Interface() != int64(10) {
+		t.Errorf("Eval wrong. got=%v, %v", value, err)
+	}
+	if out.String() != "hello!\n" {
+		t.Errorf("output wrong. got=%q", out.String())
+	}
+
+	value, err = interpreter.Call("shout", "hey")
+	if err != nil || value.Interface() != int64(6) {
+		t.Errorf("Call(shout) wrong. got=%v, %v", value, err)
+	}
+
+	if _, err := interpreter.Call("shout"); err == nil || err.Error() != "wrong number of arguments: want=1, got=0" {
+		t.Errorf("Call(shout) with no arguments wrong. got=%v", err)
+	}
+
+	if greeting, ok := interpreter.Get("greeting"); !ok || greeting.Interface() != "hello" {
+		t.Errorf("Get(greeting) wrong. got=%v, %t", greeting, ok)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = interpreter.Run(ctx, "let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(10);")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error wrong. got=%v", err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	interpreter, _ := NewInterpreterWithEngine(ENGINE_VM)
+
+	bytecode, err := interpreter.Compile("script.monkey", "let x = 1; puts(x);")
+	if err != nil {
+		t.Fatalf("Compile failed: %s", err)
+	}
+	if strings.Join(bytecode.GlobalNames, ",") != "puts,x" {
+		t.Errorf("GlobalNames wrong. got=%v", bytecode.GlobalNames)
+	}
+
+	var errorList diagnostic.ErrorList
+	if _, err := interpreter.Compile("script.monkey", "let = 1;"); !errors.As(err, &errorList) {
+		t.Errorf("syntax error wrong. got=%#v", err)
+	}
+
+	if _, err := NewInterpreter().Compile("script.monkey", "1"); err == nil || err.Error() != "compile script.monkey: only the vm engine compiles to bytecode" {
+		t.Errorf("eval engine Compile wrong. got=%v", err)
+	}
+}
+
+func TestRunBytecode(t *testing.T) {
+	builder, _ := NewInterpreterWithEngine(ENGINE_VM)
+	builder.Set("limit", 3)
+
+	bytecode, err := builder.Compile("", "let double = fn(x) { x * 2 }; let result = double(limit);")
+	if err != nil {
+		t.Fatalf("Compile failed: %s", err)
+	}
+
+	runner, _ := NewInterpreterWithEngine(ENGINE_VM)
+	runner.Set("limit", 5)
+
+	if err := runner.RunBytecode(context.Background(), bytecode); err != nil {
+		t.Fatalf("RunBytecode failed: %s", err)
+	}
+
+	if result, ok := runner.Get("result"); !ok || result.Interface() != int64(10) {
+		t.Errorf("result wrong. got=%v, %t", result, ok)
+	}
+	if value, err := runner.Call("double", 21); err != nil || value.Interface() != int64(42) {
+		t.Errorf("Call(double) wrong. got=%v, %v", value, err)
+	}
+
+	mismatched, _ := NewInterpreterWithEngine(ENGINE_VM)
+	mismatched.Set("other", 1)
+	if err := mismatched.RunBytecode(context.Background(), bytecode); err == nil || err.Error() != "bytecode expects global 1 to be limit, but it is other" {
+		t.Errorf("mismatched globals wrong. got=%v", err)
+	}
+
+	if err := NewInterpreter().RunBytecode(context.Background(), bytecode); err == nil {
+		t.Errorf("expected the eval engine to refuse bytecode")
+	}
+}
@@ -0,0 +1,332 @@
+package monkey
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+// Value is a Monkey value handed back to Go. The zero Value is null.
+type Value struct {
+	object object.Object
+}
+
+// Object returns the underlying Monkey object.
+func (value Value) Object() object.Object {
+	if value.object == nil {
+		return evaluator.NULL
+	}
+	return value.object
+}
+
+func (value Value) Type() object.ObjectType { return value.Object().Type() }
+func (value Value) String() string          { return value.Object().Inspect() }
+func (value Value) IsNull() bool            { return value.Object() == evaluator.NULL }
+
+// Interface converts the value to a plain Go value as described on
+// FromObject.
+func (value Value) Interface() interface{} { return FromObject(value.Object()) }
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+var objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+
+// ToObject converts a Go value to a Monkey object:
+//
+//   - nil and nil pointers become null
+//   - booleans, integers, floats and strings become their Monkey equivalent
+//   - slices and arrays become arrays, maps become hashes
+//   - functions become builtins that convert their arguments and results
+//
+// Objects and Values are passed through unchanged. A function may return
+// nothing, one value, or a value and an error; a non-nil error becomes a
+// Monkey error.
+func ToObject(goValue interface{}) (object.Object, error) {
+	switch goValue := goValue.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return goValue, nil
+	case Value:
+		return goValue.Object(), nil
+	case func(arguments ...object.Object) object.Object:
+		return &object.Builtin{Function: goValue}, nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Function: goValue}, nil
+	}
+
+	return toObject(reflect.ValueOf(goValue))
+}
+
+func toObject(goValue reflect.Value) (object.Object, error) {
+	switch goValue.Kind() {
+	case reflect.Bool:
+		if goValue.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: goValue.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if goValue.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d does not fit in a Monkey integer", goValue.Uint())
+		}
+		return &object.Integer{Value: int64(goValue.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: goValue.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: goValue.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if goValue.Kind() == reflect.Slice && goValue.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, goValue.Len())
+		for index := range elements {
+			element, err := toObject(goValue.Index(index))
+			if err != nil {
+				return nil, err
+			}
+			elements[index] = element
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if goValue.IsNil() {
+			return evaluator.NULL, nil
+		}
+		hash := object.NewHash()
+		iterator := goValue.MapRange()
+		for iterator.Next() {
+			key, err := toObject(iterator.Key())
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := toObject(iterator.Value())
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashKey, value)
+		}
+		return hash, nil
+
+	case reflect.Func:
+		if goValue.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return wrapFunction(goValue), nil
+
+	case reflect.Pointer, reflect.Interface:
+		if goValue.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if obj, ok := goValue.Interface().(object.Object); ok {
+			return obj, nil
+		}
+		return toObject(goValue.Elem())
+
+	case reflect.Struct:
+		if value, ok := goValue.Interface().(Value); ok {
+			return value.Object(), nil
+		}
+
+	case reflect.Invalid:
+		return evaluator.NULL, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey value", goValue.Type())
+}
+
+// FromObject converts a Monkey object to a plain Go value: integers become
+// int64, floats float64, strings string, booleans bool and null nil. Arrays
+// become []interface{} and hashes map[interface{}]interface{}. Functions
+// and other objects are returned as they are.
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for index, element := range obj.Elements {
+			elements[index] = FromObject(element)
+		}
+		return elements
+	case *object.Hash:
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs[FromObject(pair.Key)] = FromObject(pair.Value)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
+
+// wrapFunction turns a Go function into a builtin that converts each
+// argument to the matching parameter type.
+func wrapFunction(function reflect.Value) *object.Builtin {
+	functionType := function.Type()
+
+	return &object.Builtin{Function: func(arguments ...object.Object) object.Object {
+		parameters := functionType.NumIn()
+		if functionType.IsVariadic() {
+			if len(arguments) < parameters-1 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments: want at least %d, got=%d", parameters-1, len(arguments))}
+			}
+		} else if errorObject := evaluator.CheckArity(arguments, parameters); errorObject != nil {
+			return errorObject
+		}
+
+		values := make([]reflect.Value, len(arguments))
+		for index, argument := range arguments {
+			var parameterType reflect.Type
+			if functionType.IsVariadic() && index >= parameters-1 {
+				parameterType = functionType.In(parameters - 1).Elem()
+			} else {
+				parameterType = functionType.In(index)
+			}
+
+			value, err := fromObjectTo(argument, parameterType)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d: %s", index+1, err)}
+			}
+			values[index] = value
+		}
+
+		results := function.Call(values)
+
+		if len(results) > 0 && functionType.Out(len(results)-1) == errorType {
+			if err, _ := results[len(results)-1].Interface().(error); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			results = results[:len(results)-1]
+		}
+		if len(results) == 0 {
+			return nil
+		}
+
+		obj, err := toObject(results[0])
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return obj
+	}}
+}
+
+// fromObjectTo converts obj to a Go value of the given type.
+func fromObjectTo(obj object.Object, goType reflect.Type) (reflect.Value, error) {
+	if goType == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+	if goType.Kind() == reflect.Interface {
+		value := FromObject(obj)
+		if value == nil {
+			return reflect.Zero(goType), nil
+		}
+		if !reflect.TypeOf(value).AssignableTo(goType) {
+			return reflect.Value{}, mismatch(obj, goType)
+		}
+		return reflect.ValueOf(value).Convert(goType), nil
+	}
+
+	value := reflect.New(goType).Elem()
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		switch goType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if value.OverflowInt(obj.Value) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, goType)
+			}
+			value.SetInt(obj.Value)
+			return value, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || value.OverflowUint(uint64(obj.Value)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, goType)
+			}
+			value.SetUint(uint64(obj.Value))
+			return value, nil
+		case reflect.Float32, reflect.Float64:
+			value.SetFloat(float64(obj.Value))
+			return value, nil
+		}
+
+	case *object.Float:
+		if goType.Kind() == reflect.Float32 || goType.Kind() == reflect.Float64 {
+			value.SetFloat(obj.Value)
+			return value, nil
+		}
+
+	case *object.String:
+		if goType.Kind() == reflect.String {
+			value.SetString(obj.Value)
+			return value, nil
+		}
+
+	case *object.Boolean:
+		if goType.Kind() == reflect.Bool {
+			value.SetBool(obj.Value)
+			return value, nil
+		}
+
+	case *object.Null:
+		switch goType.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Func:
+			return value, nil
+		}
+
+	case *object.Array:
+		if goType.Kind() == reflect.Slice {
+			value = reflect.MakeSlice(goType, len(obj.Elements), len(obj.Elements))
+			for index, element := range obj.Elements {
+				converted, err := fromObjectTo(element, goType.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				value.Index(index).Set(converted)
+			}
+			return value, nil
+		}
+
+	case *object.Hash:
+		if goType.Kind() == reflect.Map {
+			value = reflect.MakeMapWithSize(goType, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				key, err := fromObjectTo(pair.Key, goType.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				converted, err := fromObjectTo(pair.Value, goType.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				value.SetMapIndex(key, converted)
+			}
+			return value, nil
+		}
+	}
+
+	return reflect.Value{}, mismatch(obj, goType)
+}
+
+func mismatch(obj object.Object, goType reflect.Type) error {
+	return fmt.Errorf("cannot use %s as %s", obj.Type(), goType)
+}
@@ -0,0 +1,62 @@
+package object
+
+import "context"
+
+type Environment struct {
+	store   map[string]Object
+	outer   *Environment
+	context context.Context
+	depth   int
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	environment := NewEnvironment()
+	environment.outer = outer
+	return environment
+}
+
+func (environment *Environment) Get(name string) (Object, bool) {
+	value, ok := environment.store[name]
+	if !ok && environment.outer != nil {
+		return environment.outer.Get(name)
+	}
+	return value, ok
+}
+
+func (environment *Environment) Set(name string, value Object) Object {
+	environment.store[name] = value
+	return value
+}
+
+// Context returns the context of the nearest environment that has one.
+// Function environments are enclosed by the globals they were defined in,
+// so setting a context on the globals reaches every call made from them.
+func (environment *Environment) Context() context.Context {
+	for ; environment != nil; environment = environment.outer {
+		if environment.context != nil {
+			return environment.context
+		}
+	}
+	return context.Background()
+}
+
+// SetContext makes ctx the context of this environment and of every
+// environment it encloses. Pass nil to clear it.
+func (environment *Environment) SetContext(ctx context.Context) {
+	environment.context = ctx
+}
+
+// Depth returns how many function calls were nested when the environment
+// was created for one of them, or 0 for the globals.
+func (environment *Environment) Depth() int {
+	return environment.depth
+}
+
+// SetDepth records how many function calls are nested in this environment.
+func (environment *Environment) SetDepth(depth int) {
+	environment.depth = depth
+}
@@ -0,0 +1,264 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/code"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJECT      = "INTEGER"
+	FLOAT_OBJECT        = "FLOAT"
+	STRING_OBJECT       = "STRING"
+	BOOLEAN_OBJECT      = "BOOLEAN"
+	NULL_OBJECT         = "NULL"
+	RETURN_VALUE_OBJECT = "RETURN_VALUE"
+	ERROR_OBJECT        = "ERROR"
+	FUNCTION_OBJECT     = "FUNCTION"
+	BUILTIN_OBJECT      = "BUILTIN"
+	ARRAY_OBJECT        = "ARRAY"
+	HASH_OBJECT         = "HASH"
+
+	COMPILED_FUNCTION_OBJECT = "COMPILED_FUNCTION"
+	CELL_OBJECT              = "CELL"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (integer *Integer) Type() ObjectType { return INTEGER_OBJECT }
+func (integer *Integer) Inspect() string  { return fmt.Sprintf("%d", integer.Value) }
+func (integer *Integer) HashKey() HashKey {
+	return HashKey{Type: integer.Type(), Value: uint64(integer.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (float *Float) Type() ObjectType { return FLOAT_OBJECT }
+func (float *Float) Inspect() string {
+	text := strconv.FormatFloat(float.Value, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eIN") {
+		text += ".0"
+	}
+	return text
+}
+
+type String struct {
+	Value string
+}
+
+func (str *String) Type() ObjectType { return STRING_OBJECT }
+func (str *String) Inspect() string  { return str.Value }
+func (str *String) HashKey() HashKey {
+	hash := fnv.New64a()
+	hash.Write([]byte(str.Value))
+
+	return HashKey{Type: str.Type(), Value: hash.Sum64()}
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (boolean *Boolean) Type() ObjectType { return BOOLEAN_OBJECT }
+func (boolean *Boolean) Inspect() string  { return fmt.Sprintf("%t", boolean.Value) }
+func (boolean *Boolean) HashKey() HashKey {
+	var value uint64
+	if boolean.Value {
+		value = 1
+	}
+
+	return HashKey{Type: boolean.Type(), Value: value}
+}
+
+type Null struct{}
+
+func (null *Null) Type() ObjectType { return NULL_OBJECT }
+func (null *Null) Inspect() string  { return "null" }
+
+type ReturnValue struct {
+	Value Object
+}
+
+func (returnValue *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJECT }
+func (returnValue *ReturnValue) Inspect() string  { return returnValue.Value.Inspect() }
+
+type Error struct {
+	Message string
+}
+
+func (errorObject *Error) Type() ObjectType { return ERROR_OBJECT }
+func (errorObject *Error) Inspect() string  { return "ERROR: " + errorObject.Message }
+
+type Function struct {
+	Parameters  []*abstractSyntaxTree.Identifier
+	Body        *abstractSyntaxTree.BlockStatement
+	Environment *Environment
+}
+
+func (function *Function) Type() ObjectType { return FUNCTION_OBJECT }
+func (function *Function) Inspect() string {
+	return FunctionSource(function.Parameters, function.Body)
+}
+
+// FunctionSource is how a function prints, for both engines.
+func FunctionSource(parameterList []*abstractSyntaxTree.Identifier, body *abstractSyntaxTree.BlockStatement) string {
+	var out bytes.Buffer
+
+	parameters := []string{}
+	for _, parameter := range parameterList {
+		parameters = append(parameters, parameter.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(parameters, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// CompiledFunction is a function literal lowered to bytecode. NumLocals
+// counts the parameters too, since they are the first locals. Name is the
+// let name the function was bound to, if any, and Source is what the
+// function prints as.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Lines         code.LineTable
+	NumLocals     int
+	NumParameters int
+	Name          string
+	Source        string
+}
+
+func (compiledFunction *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJECT }
+func (compiledFunction *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", compiledFunction)
+}
+
+// Closure is a compiled function together with the free variables it
+// captured when it was created. The VM only ever calls closures, so to a
+// program a closure is a function like any other.
+type Closure struct {
+	Function *CompiledFunction
+	Free     []*Cell
+}
+
+func (closure *Closure) Type() ObjectType { return FUNCTION_OBJECT }
+func (closure *Closure) Inspect() string  { return closure.Function.Source }
+
+// Cell holds a local variable once a closure has captured it, so that the
+// closure sees the value the variable has when it runs, as an evaluated
+// function would.
+type Cell struct {
+	Value Object
+}
+
+func (cell *Cell) Type() ObjectType { return CELL_OBJECT }
+func (cell *Cell) Inspect() string  { return fmt.Sprintf("Cell[%p]", cell) }
+
+type BuiltinFunction func(arguments ...Object) Object
+
+type Builtin struct {
+	Name     string
+	Function BuiltinFunction
+}
+
+func (builtin *Builtin) Type() ObjectType { return BUILTIN_OBJECT }
+func (builtin *Builtin) Inspect() string  { return "builtin function" }
+
+type Array struct {
+	Elements []Object
+}
+
+func (array *Array) Type() ObjectType { return ARRAY_OBJECT }
+func (array *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, element := range array.Elements {
+		elements = append(elements, element.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey identifies a hash entry by value, so that two distinct String
+// objects with the same contents find the same pair.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash remembers the order its keys were first inserted in, so that
+// inspecting it is deterministic.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (hash *Hash) Type() ObjectType { return HASH_OBJECT }
+func (hash *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, key := range hash.Keys {
+		pair := hash.Pairs[key]
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Set adds or replaces the pair stored under key's hash key.
+func (hash *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := hash.Pairs[hashKey]; !ok {
+		hash.Keys = append(hash.Keys, hashKey)
+	}
+	hash.Pairs[hashKey] = HashPair{Key: key.(Object), Value: value}
+}
+
+// Get returns the value stored under key's hash key.
+func (hash *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := hash.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
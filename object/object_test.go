@@ -0,0 +1,55 @@
+package object
+
+import "testing"
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("1 and true have the same hash key")
+	}
+
+	if (&Boolean{Value: false}).HashKey() == yes.HashKey() {
+		t.Errorf("false and true have the same hash key")
+	}
+}
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "b"}, &Integer{Value: 2})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 3})
+
+	if hash.Inspect() != "{b: 3, a: 1}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", hash.Inspect())
+	}
+
+	value, ok := hash.Get(&String{Value: "a"})
+	if !ok || value.Inspect() != "1" {
+		t.Errorf("hash.Get(a) wrong. got=%v, %t", value, ok)
+	}
+
+	if _, ok := hash.Get(&Integer{Value: 1}); ok {
+		t.Errorf("hash.Get(1) found a pair")
+	}
+}
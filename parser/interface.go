@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"os"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/lexer"
+)
+
+// ParseString parses src and returns the program together with every
+// diagnostic reported while parsing, as a diagnostic.ErrorList. The program
+// is returned even when there are errors, with BadStatement and
+// BadExpression nodes standing in for the broken parts.
+func ParseString(src string) (*abstractSyntaxTree.Program, error) {
+	return ParseSource("", src)
+}
+
+// ParseFile reads and parses the file at path. Positions in the returned
+// program and diagnostics carry path as their filename.
+func ParseFile(path string) (*abstractSyntaxTree.Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSource(path, string(src))
+}
+
+// ParseSource parses src as if it was read from filename.
+func ParseSource(filename string, src string) (*abstractSyntaxTree.Program, error) {
+	parser := NewParser(lexer.NewFileLexer(filename, src))
+	program := parser.ParseProgram()
+
+	errors := parser.Errors()
+	errors.Sort()
+
+	return program, errors.Err()
+}
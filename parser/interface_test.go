@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/diagnostic"
+)
+
+func TestParseString(t *testing.T) {
+	program, err := ParseString("let x = 1 + 2; x")
+	if err != nil {
+		t.Fatalf("ParseString returned error: %s", err)
+	}
+
+	if program.String() != "let x = (1 + 2);x" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestParseStringErrors(t *testing.T) {
+	program, err := ParseString("let = 1;\nlet y = ;")
+	if err == nil {
+		t.Fatalf("ParseString did not return an error")
+	}
+
+	if program == nil {
+		t.Fatalf("ParseString did not return the partial program")
+	}
+
+	var errorList diagnostic.ErrorList
+	if !errors.As(err, &errorList) {
+		t.Fatalf("error is not a diagnostic.ErrorList. got=%T", err)
+	}
+
+	if len(errorList) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(errorList))
+	}
+
+	if errorList[0].Span.Start.Line != 1 || errorList[1].Span.Start.Line != 2 {
+		t.Errorf("errors are not sorted by position: %v", errorList)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(path, []byte("let a = 1;\nlet = 2;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ParseFile(path)
+	if err == nil {
+		t.Fatalf("ParseFile did not return an error")
+	}
+
+	expected := path + ":2:5: error[E0001]: expected next token to be IDENT, got = instead"
+	if err.Error() != expected {
+		t.Errorf("error wrong. expected=%q, got=%q", expected, err.Error())
+	}
+
+	if _, err := ParseFile(filepath.Join(t.TempDir(), "missing.monkey")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error for a missing file. got=%v", err)
+	}
+}
@@ -2,19 +2,31 @@ package parser
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 
 	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/diagnostic"
 	"github.com/Favot/monkey-interpreter/lexer"
 	"github.com/Favot/monkey-interpreter/token"
 )
 
 type Parser struct {
 	lexer  *lexer.Lexer
-	errors []string
+	errors diagnostic.ErrorList
+
+	panicking  bool
+	bailout    bool
+	blockDepth int
+
+	// holdCurrentToken makes the next nextToken call a no-op, so that a `}`
+	// which ended a broken statement still closes its enclosing block.
+	holdCurrentToken bool
 
 	currentToken token.Token
 	lookahead    token.Token
+	comments     []*abstractSyntaxTree.CommentGroup
 
 	prefixParseFunctions map[token.TokenType]prefixParseFunction
 	infixParseFunctions  map[token.TokenType]infixParseFunction
@@ -25,30 +37,65 @@ type (
 	infixParseFunction  func(abstractSyntaxTree.Expression) abstractSyntaxTree.Expression
 )
 
+const MAX_ERRORS = 10
+
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESS_GREATER
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
+	INDEX
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQUALS:       EQUALS,
-	token.NOT_EQUALS:   EQUALS,
-	token.LESS_THAN:    LESS_GREATER,
-	token.GREATER_THAN: LESS_GREATER,
-	token.ADD:          SUM,
-	token.MINUS:        SUM,
-	token.SLASH:        PRODUCT,
-	token.ASTERISK:     PRODUCT,
+	token.EQUALS:           EQUALS,
+	token.NOT_EQUALS:       EQUALS,
+	token.OR:               LOGICAL_OR,
+	token.AND:              LOGICAL_AND,
+	token.LESS_THAN:        LESS_GREATER,
+	token.GREATER_THAN:     LESS_GREATER,
+	token.LESS_EQUAL:       LESS_GREATER,
+	token.GREATER_EQUAL:    LESS_GREATER,
+	token.ADD:              SUM,
+	token.MINUS:            SUM,
+	token.SLASH:            PRODUCT,
+	token.ASTERISK:         PRODUCT,
+	token.PERCENT:          PRODUCT,
+	token.POWER:            POWER,
+	token.LEFT_PARENTHESIS: CALL,
+	token.LEFT_BRACKET:     INDEX,
+}
+
+// rightAssociative lists the operators whose right operand may itself be an
+// operation of the same precedence, so that 2 ** 3 ** 2 is 2 ** (3 ** 2).
+var rightAssociative = map[token.TokenType]bool{
+	token.POWER: true,
+}
+
+// Precedence returns how tightly the binary operator binds its operands, or
+// LOWEST when operator is not one.
+func Precedence(operator token.TokenType) int {
+	if precedence, ok := precedences[operator]; ok {
+		return precedence
+	}
+	return LOWEST
+}
+
+// IsRightAssociative reports whether a chain of operator groups to the
+// right.
+func IsRightAssociative(operator token.TokenType) bool {
+	return rightAssociative[operator]
 }
 
 func NewParser(lexer *lexer.Lexer) *Parser {
-	parser := &Parser{lexer: lexer, errors: []string{}}
+	parser := &Parser{lexer: lexer, errors: diagnostic.ErrorList{}}
 
 	parser.nextToken()
 	parser.nextToken()
@@ -56,8 +103,18 @@ func NewParser(lexer *lexer.Lexer) *Parser {
 	parser.prefixParseFunctions = make(map[token.TokenType]prefixParseFunction)
 	parser.regiesterPrefix(token.IDENT, parser.parseIndifier)
 	parser.regiesterPrefix(token.INT, parser.parseIntegerLiteral)
+	parser.regiesterPrefix(token.FLOAT, parser.parseFloatLiteral)
+	parser.regiesterPrefix(token.STRING, parser.parseStringLiteral)
+	parser.regiesterPrefix(token.ILLEGAL, parser.parseIllegal)
 	parser.regiesterPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.regiesterPrefix(token.MINUS, parser.parsePrefixExpression)
+	parser.regiesterPrefix(token.TRUE, parser.parseBoolean)
+	parser.regiesterPrefix(token.FALSE, parser.parseBoolean)
+	parser.regiesterPrefix(token.LEFT_PARENTHESIS, parser.parseGroupedExpression)
+	parser.regiesterPrefix(token.IF, parser.parseIfExpression)
+	parser.regiesterPrefix(token.FUNCTION, parser.parseFunctionLiteral)
+	parser.regiesterPrefix(token.LEFT_BRACKET, parser.parseArrayLiteral)
+	parser.regiesterPrefix(token.LEFT_BRACE, parser.parseHashLiteral)
 
 	parser.infixParseFunctions = make(map[token.TokenType]infixParseFunction)
 	parser.registerInfix(token.ADD, parser.parseInfixExpression)
@@ -68,54 +125,181 @@ func NewParser(lexer *lexer.Lexer) *Parser {
 	parser.registerInfix(token.NOT_EQUALS, parser.parseInfixExpression)
 	parser.registerInfix(token.LESS_THAN, parser.parseInfixExpression)
 	parser.registerInfix(token.GREATER_THAN, parser.parseInfixExpression)
+	parser.registerInfix(token.LESS_EQUAL, parser.parseInfixExpression)
+	parser.registerInfix(token.GREATER_EQUAL, parser.parseInfixExpression)
+	parser.registerInfix(token.PERCENT, parser.parseInfixExpression)
+	parser.registerInfix(token.POWER, parser.parseInfixExpression)
+	parser.registerInfix(token.AND, parser.parseInfixExpression)
+	parser.registerInfix(token.OR, parser.parseInfixExpression)
+	parser.registerInfix(token.LEFT_PARENTHESIS, parser.parseCallExpression)
+	parser.registerInfix(token.LEFT_BRACKET, parser.parseIndexExpression)
 
 	return parser
 }
 
-func (parser *Parser) Errors() []string {
-	return parser.errors
+// Errors returns the diagnostics reported by both the lexer and the parser,
+// sorted by position.
+func (parser *Parser) Errors() diagnostic.ErrorList {
+	errors := append(diagnostic.ErrorList{}, parser.lexer.Errors()...)
+	errors = append(errors, parser.errors...)
+	errors.Sort()
+
+	return errors
+}
+
+// addError records a diagnostic and puts the parser in panic mode. Anything
+// reported while panicking is a consequence of the first error and is dropped
+// until parseStatement has synchronized.
+func (parser *Parser) addError(report *diagnostic.Diagnostic) {
+	if parser.panicking || parser.bailout {
+		return
+	}
+
+	parser.panicking = true
+
+	if len(parser.errors) >= MAX_ERRORS {
+		parser.errors.Add(diagnostic.New(diagnostic.TOO_MANY_ERRORS, report.Span,
+			"too many errors, giving up after %d", MAX_ERRORS))
+		parser.bailout = true
+		return
+	}
+
+	parser.errors.Add(report)
 }
 
 func (parser *Parser) peekError(nextToken token.TokenType) {
-	message := fmt.Sprintf("expected next token to be %s, got %s instead", nextToken, parser.currentToken.Type)
+	// The lexer has already reported why it rejected an illegal token, and
+	// that is the more useful error.
+	if parser.lookahead.Type == token.ILLEGAL {
+		parser.panicking = true
+		return
+	}
+
+	report := diagnostic.New(diagnostic.UNEXPECTED_TOKEN, parser.lookahead.Span,
+		"expected next token to be %s, got %s instead", nextToken, parser.lookahead.Type)
 
-	parser.errors = append(parser.errors, message)
+	if isDelimiter(nextToken) {
+		report.WithFix(diagnostic.Fix{
+			Message:     fmt.Sprintf("insert `%s` before %s", nextToken, describeToken(parser.lookahead)),
+			Span:        token.Span{Start: parser.lookahead.Span.Start, End: parser.lookahead.Span.Start},
+			Replacement: string(nextToken),
+		})
+	}
+
+	parser.addError(report)
+}
+
+func isDelimiter(tokenType token.TokenType) bool {
+	switch tokenType {
+	case token.SEMICOLON, token.COMMA, token.COLON, token.ASSIGN,
+		token.LEFT_PARENTHESIS, token.RIGHT_PARENTHESIS,
+		token.LEFT_BRACE, token.RIGHT_BRACE,
+		token.LEFT_BRACKET, token.RIGHT_BRACKET:
+		return true
+	}
+	return false
+}
+
+func describeToken(currentToken token.Token) string {
+	if currentToken.Type == token.EOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("`%s`", currentToken.Literal)
 }
 
 func (parser *Parser) nextToken() {
+	if parser.holdCurrentToken {
+		parser.holdCurrentToken = false
+		return
+	}
+
 	parser.currentToken = parser.lookahead
 	parser.lookahead = parser.lexer.NextToken()
+
+	parser.comments = append(parser.comments, abstractSyntaxTree.GroupComments(parser.lookahead.LeadingTrivia)...)
+	parser.comments = append(parser.comments, abstractSyntaxTree.GroupComments(parser.lookahead.TrailingTrivia)...)
 }
 
-func (parser *Parser) parseProgram() *abstractSyntaxTree.Program {
+func (parser *Parser) ParseProgram() *abstractSyntaxTree.Program {
 	program := &abstractSyntaxTree.Program{}
 	program.Statements = []abstractSyntaxTree.Statement{}
 
-	for parser.currentToken.Type != token.EOF {
-		parserStatement := parser.parseStatement()
-		if parserStatement != nil {
-			program.Statements = append(program.Statements, parserStatement)
-		}
+	for parser.currentToken.Type != token.EOF && !parser.bailout {
+		program.Statements = append(program.Statements, parser.parseStatement())
 		parser.nextToken()
 	}
 
+	program.Comments = parser.comments
+
 	return program
 
 }
 
 func (parser *Parser) parseStatement() abstractSyntaxTree.Statement {
+	start := parser.currentToken
+
+	var statement abstractSyntaxTree.Statement
+
 	switch parser.currentToken.Type {
 	case token.LET:
-		return parser.parseLetStatement()
+		statement = parser.parseLetStatement()
 	case token.RETURN:
-		return parser.parseReturnStatement()
+		statement = parser.parseReturnStatement()
 	default:
-		return parser.parseExpressionStatement()
+		statement = parser.parseExpressionStatement()
+	}
+
+	if parser.panicking {
+		parser.synchronize()
+		parser.panicking = false
+	}
+
+	if statement == nil {
+		statement = &abstractSyntaxTree.BadStatement{Token: start, From: start.Span.Start, To: parser.currentToken.Span.End}
+	}
+
+	return statement
+}
+
+// synchronize skips tokens until the parser reaches a point where a new
+// statement can start: just after a `;`, or just before a statement keyword,
+// a closing `}` or the end of input. Braces opened while skipping are matched
+// so a half-parsed block is discarded as a whole.
+func (parser *Parser) synchronize() {
+	if parser.currentTokenIs(token.RIGHT_BRACE) && parser.blockDepth > 0 {
+		parser.holdCurrentToken = true
+		return
+	}
+
+	depth := 0
+
+	for !parser.currentTokenIs(token.EOF) {
+		switch parser.currentToken.Type {
+		case token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_BRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			switch parser.lookahead.Type {
+			case token.LET, token.RETURN, token.FUNCTION, token.IF, token.RIGHT_BRACE, token.EOF:
+				return
+			}
+		}
+
+		parser.nextToken()
 	}
 }
 
-func (parser *Parser) parseLetStatement() *abstractSyntaxTree.LetStatement {
-	letStatement := &abstractSyntaxTree.LetStatement{Token: parser.currentToken}
+func (parser *Parser) parseLetStatement() abstractSyntaxTree.Statement {
+	letStatement := &abstractSyntaxTree.LetStatement{Token: parser.currentToken, Doc: docComment(parser.currentToken)}
 
 	if !parser.expectPeek(token.IDENT) {
 		return nil
@@ -127,9 +311,14 @@ func (parser *Parser) parseLetStatement() *abstractSyntaxTree.LetStatement {
 		return nil
 	}
 
-	for !parser.currentTokenIs(token.SEMICOLON) {
+	parser.nextToken()
+
+	letStatement.Value = parser.parseExpression(LOWEST)
+
+	if !parser.panicking && parser.peekNextTokenIs(token.SEMICOLON) {
 		parser.nextToken()
 	}
+
 	return letStatement
 }
 
@@ -151,15 +340,22 @@ func (parser *Parser) expectPeek(tokenType token.TokenType) bool {
 	}
 }
 
-func (parser *Parser) parseReturnStatement() *abstractSyntaxTree.ReturnStatement {
+func (parser *Parser) parseReturnStatement() abstractSyntaxTree.Statement {
 
 	statement := &abstractSyntaxTree.ReturnStatement{
 		Token: parser.currentToken,
 	}
 
+	if parser.peekNextTokenIs(token.SEMICOLON) || parser.peekNextTokenIs(token.EOF) {
+		parser.nextToken()
+		return statement
+	}
+
 	parser.nextToken()
 
-	for !parser.currentTokenIs(token.SEMICOLON) {
+	statement.ReturnValue = parser.parseExpression(LOWEST)
+
+	if !parser.panicking && parser.peekNextTokenIs(token.SEMICOLON) {
 		parser.nextToken()
 	}
 
@@ -179,7 +375,7 @@ func (parser *Parser) parseExpressionStatement() *abstractSyntaxTree.ExpressionS
 
 	statement.Expression = parser.parseExpression(LOWEST)
 
-	if parser.peekNextTokenIs(token.SEMICOLON) {
+	if !parser.panicking && parser.peekNextTokenIs(token.SEMICOLON) {
 		parser.nextToken()
 	}
 
@@ -188,15 +384,20 @@ func (parser *Parser) parseExpressionStatement() *abstractSyntaxTree.ExpressionS
 
 func (parser *Parser) parseExpression(precedent int) abstractSyntaxTree.Expression {
 
+	start := parser.currentToken
+
 	prefix := parser.prefixParseFunctions[parser.currentToken.Type]
 	if prefix == nil {
-		parser.noPrefixParseFunctionError(parser.currentToken.Type)
-		return nil
+		parser.noPrefixParseFunctionError(parser.currentToken)
+		return parser.badExpression(start)
 	}
 
 	leftExpression := prefix()
+	if leftExpression == nil {
+		return parser.badExpression(start)
+	}
 
-	for !parser.peekNextTokenIs(token.SEMICOLON) && precedent < parser.peekPrecedence() {
+	for !parser.panicking && !parser.peekNextTokenIs(token.SEMICOLON) && precedent < parser.peekPrecedence() {
 		infix := parser.infixParseFunctions[parser.lookahead.Type]
 
 		if infix == nil {
@@ -218,11 +419,14 @@ func (parser *Parser) parseIndifier() abstractSyntaxTree.Expression {
 func (parser *Parser) parseIntegerLiteral() abstractSyntaxTree.Expression {
 	literal := &abstractSyntaxTree.IntegerLiteral{Token: parser.currentToken}
 
-	value, err := strconv.ParseInt(parser.currentToken.Literal, 0, 64)
+	digits, base := lexer.IntegerDigits(parser.currentToken.Literal)
+
+	value, err := strconv.ParseInt(digits, base, 64)
 
 	if err != nil {
-		message := fmt.Sprintf("coulf not parse %q as integer", parser.currentToken.Literal)
-		parser.errors = append(parser.errors, message)
+		parser.addError(diagnostic.New(diagnostic.NUMBER_OUT_OF_RANGE, parser.currentToken.Span,
+			"integer literal %s does not fit in 64 bits", parser.currentToken.Literal).
+			WithNote("integers range from %d to %d", math.MinInt64, math.MaxInt64))
 		return nil
 	}
 
@@ -231,11 +435,41 @@ func (parser *Parser) parseIntegerLiteral() abstractSyntaxTree.Expression {
 	return literal
 }
 
-func (parser *Parser) noPrefixParseFunctionError(tokenType token.TokenType) {
-	message := fmt.Sprintf("no prefix parse fuinction for %s found", tokenType)
+func (parser *Parser) parseFloatLiteral() abstractSyntaxTree.Expression {
+	literal := &abstractSyntaxTree.FloatLiteral{Token: parser.currentToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(parser.currentToken.Literal, "_", ""), 64)
 
-	parser.errors = append(parser.errors, message)
+	if err != nil {
+		parser.addError(diagnostic.New(diagnostic.NUMBER_OUT_OF_RANGE, parser.currentToken.Span,
+			"float literal %s is out of range", parser.currentToken.Literal).
+			WithNote("the largest float is about %g", math.MaxFloat64))
+		return nil
+	}
+
+	literal.Value = value
 
+	return literal
+}
+
+// parseIllegal turns a token the lexer rejected into a BadExpression. The
+// lexer has already reported why, so the parser only enters panic mode.
+func (parser *Parser) parseIllegal() abstractSyntaxTree.Expression {
+	parser.panicking = true
+	return nil
+}
+
+func (parser *Parser) parseStringLiteral() abstractSyntaxTree.Expression {
+	return &abstractSyntaxTree.StringLiteral{Token: parser.currentToken, Value: parser.currentToken.Literal}
+}
+
+func (parser *Parser) noPrefixParseFunctionError(currentToken token.Token) {
+	parser.addError(diagnostic.New(diagnostic.NO_PREFIX_PARSE_FUNCTION, currentToken.Span,
+		"expected an expression, got %s", describeToken(currentToken)))
+}
+
+func (parser *Parser) badExpression(start token.Token) abstractSyntaxTree.Expression {
+	return &abstractSyntaxTree.BadExpression{Token: start, From: start.Span.Start, To: parser.currentToken.Span.End}
 }
 
 func (parser *Parser) parsePrefixExpression() abstractSyntaxTree.Expression {
@@ -254,18 +488,11 @@ func (parser *Parser) parsePrefixExpression() abstractSyntaxTree.Expression {
 }
 
 func (parser *Parser) peekPrecedence() int {
-	if precedence, ok := precedences[parser.lookahead.Type]; ok {
-		return precedence
-	}
-	return LOWEST
+	return Precedence(parser.lookahead.Type)
 }
 
 func (parser *Parser) currentPrecedence() int {
-	if precedence, ok := precedences[parser.currentToken.Type]; ok {
-		return precedence
-	}
-
-	return LOWEST
+	return Precedence(parser.currentToken.Type)
 }
 
 func (parser *Parser) parseInfixExpression(left abstractSyntaxTree.Expression) abstractSyntaxTree.Expression {
@@ -276,8 +503,274 @@ func (parser *Parser) parseInfixExpression(left abstractSyntaxTree.Expression) a
 	}
 
 	precedence := parser.currentPrecedence()
+	if IsRightAssociative(parser.currentToken.Type) {
+		precedence--
+	}
+
 	parser.nextToken()
 	expression.Right = parser.parseExpression(precedence)
 
 	return expression
 }
+
+func (parser *Parser) parseBoolean() abstractSyntaxTree.Expression {
+	return &abstractSyntaxTree.Boolean{Token: parser.currentToken, Value: parser.currentTokenIs(token.TRUE)}
+}
+
+func (parser *Parser) parseGroupedExpression() abstractSyntaxTree.Expression {
+	parser.nextToken()
+
+	expression := parser.parseExpression(LOWEST)
+
+	if !parser.expectPeek(token.RIGHT_PARENTHESIS) {
+		return nil
+	}
+
+	return expression
+}
+
+func (parser *Parser) parseIfExpression() abstractSyntaxTree.Expression {
+	expression := &abstractSyntaxTree.IfExpression{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LEFT_PARENTHESIS) {
+		return nil
+	}
+
+	parser.nextToken()
+	expression.Condition = parser.parseExpression(LOWEST)
+
+	if !parser.expectPeek(token.RIGHT_PARENTHESIS) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.LEFT_BRACE) {
+		return nil
+	}
+
+	expression.Consequence = parser.parseBlockStatement()
+
+	if parser.peekNextTokenIs(token.ELSE) {
+		parser.nextToken()
+
+		if !parser.expectPeek(token.LEFT_BRACE) {
+			return nil
+		}
+
+		expression.Alternative = parser.parseBlockStatement()
+	}
+
+	return expression
+}
+
+func (parser *Parser) parseBlockStatement() *abstractSyntaxTree.BlockStatement {
+	block := &abstractSyntaxTree.BlockStatement{Token: parser.currentToken}
+	block.Statements = []abstractSyntaxTree.Statement{}
+
+	parser.blockDepth++
+	defer func() { parser.blockDepth-- }()
+
+	parser.nextToken()
+
+	for !parser.currentTokenIs(token.RIGHT_BRACE) && !parser.currentTokenIs(token.EOF) && !parser.bailout {
+		block.Statements = append(block.Statements, parser.parseStatement())
+		parser.nextToken()
+	}
+
+	if parser.currentTokenIs(token.EOF) {
+		parser.addError(diagnostic.New(diagnostic.UNTERMINATED_BLOCK, block.Token.Span,
+			"block is never closed").
+			WithNote("reached end of input while looking for `}`").
+			WithFix(diagnostic.Fix{
+				Message:     "insert `}` at end of input",
+				Span:        token.Span{Start: parser.currentToken.Span.Start, End: parser.currentToken.Span.Start},
+				Replacement: "}",
+			}))
+	} else {
+		block.RightBrace = parser.currentToken
+	}
+
+	return block
+}
+
+func (parser *Parser) parseFunctionLiteral() abstractSyntaxTree.Expression {
+	literal := &abstractSyntaxTree.FunctionLiteral{Token: parser.currentToken}
+
+	if !parser.expectPeek(token.LEFT_PARENTHESIS) {
+		return nil
+	}
+
+	literal.Parameters = parser.parseFunctionParameters()
+
+	if !parser.expectPeek(token.LEFT_BRACE) {
+		return nil
+	}
+
+	literal.Body = parser.parseBlockStatement()
+
+	return literal
+}
+
+func (parser *Parser) parseFunctionParameters() []*abstractSyntaxTree.Identifier {
+	identifiers := []*abstractSyntaxTree.Identifier{}
+
+	if parser.peekNextTokenIs(token.RIGHT_PARENTHESIS) {
+		parser.nextToken()
+		return identifiers
+	}
+
+	if !parser.expectPeek(token.IDENT) {
+		return nil
+	}
+	identifiers = append(identifiers, &abstractSyntaxTree.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal})
+
+	for parser.peekNextTokenIs(token.COMMA) {
+		parser.nextToken()
+		if !parser.expectPeek(token.IDENT) {
+			return nil
+		}
+		identifiers = append(identifiers, &abstractSyntaxTree.Identifier{Token: parser.currentToken, Value: parser.currentToken.Literal})
+	}
+
+	if !parser.expectPeek(token.RIGHT_PARENTHESIS) {
+		return nil
+	}
+
+	return identifiers
+}
+
+func (parser *Parser) parseCallExpression(function abstractSyntaxTree.Expression) abstractSyntaxTree.Expression {
+	expression := &abstractSyntaxTree.CallExpression{Token: parser.currentToken, Function: function}
+	expression.Arguments = parser.parseExpressionList(token.RIGHT_PARENTHESIS)
+
+	if parser.currentTokenIs(token.RIGHT_PARENTHESIS) {
+		expression.RightParenthesis = parser.currentToken
+	}
+
+	return expression
+}
+
+func (parser *Parser) parseArrayLiteral() abstractSyntaxTree.Expression {
+	array := &abstractSyntaxTree.ArrayLiteral{Token: parser.currentToken}
+	array.Elements = parser.parseExpressionList(token.RIGHT_BRACKET)
+
+	if parser.currentTokenIs(token.RIGHT_BRACKET) {
+		array.RightBracket = parser.currentToken
+	}
+
+	return array
+}
+
+func (parser *Parser) parseIndexExpression(left abstractSyntaxTree.Expression) abstractSyntaxTree.Expression {
+	expression := &abstractSyntaxTree.IndexExpression{Token: parser.currentToken, Left: left}
+
+	parser.nextToken()
+	expression.Index = parser.parseExpression(LOWEST)
+
+	if !parser.panicking && parser.expectPeek(token.RIGHT_BRACKET) {
+		expression.RightBracket = parser.currentToken
+	}
+
+	return expression
+}
+
+// parseHashLiteral is the prefix function for `{`. Blocks are only parsed
+// directly after if, else and fn, so a brace in expression position always
+// opens a hash.
+func (parser *Parser) parseHashLiteral() abstractSyntaxTree.Expression {
+	hash := &abstractSyntaxTree.HashLiteral{Token: parser.currentToken}
+	hash.Pairs = []abstractSyntaxTree.HashPair{}
+
+	for !parser.peekNextTokenIs(token.RIGHT_BRACE) {
+		parser.nextToken()
+		pair := abstractSyntaxTree.HashPair{Key: parser.parseExpression(LOWEST)}
+
+		if parser.panicking || !parser.expectPeek(token.COLON) {
+			hash.Pairs = append(hash.Pairs, pair)
+			return parser.recoverHashLiteral(hash)
+		}
+
+		parser.nextToken()
+		pair.Value = parser.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, pair)
+
+		if parser.panicking {
+			return parser.recoverHashLiteral(hash)
+		}
+		if !parser.peekNextTokenIs(token.RIGHT_BRACE) && !parser.expectPeek(token.COMMA) {
+			return parser.recoverHashLiteral(hash)
+		}
+	}
+
+	parser.nextToken()
+	hash.RightBrace = parser.currentToken
+
+	return hash
+}
+
+// recoverHashLiteral skips the rest of a malformed hash up to its closing
+// brace and leaves panic mode there. Without it synchronize would mistake
+// that brace for the end of the enclosing block.
+func (parser *Parser) recoverHashLiteral(hash *abstractSyntaxTree.HashLiteral) abstractSyntaxTree.Expression {
+	if parser.bailout {
+		return hash
+	}
+
+	depth := 0
+
+	for !parser.currentTokenIs(token.EOF) {
+		switch parser.currentToken.Type {
+		case token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_BRACE:
+			if depth == 0 {
+				hash.RightBrace = parser.currentToken
+				parser.panicking = false
+				return hash
+			}
+			depth--
+		}
+		parser.nextToken()
+	}
+
+	return hash
+}
+
+func (parser *Parser) parseExpressionList(end token.TokenType) []abstractSyntaxTree.Expression {
+	list := []abstractSyntaxTree.Expression{}
+
+	if parser.peekNextTokenIs(end) {
+		parser.nextToken()
+		return list
+	}
+
+	parser.nextToken()
+	list = append(list, parser.parseExpression(LOWEST))
+
+	for !parser.panicking && parser.peekNextTokenIs(token.COMMA) {
+		parser.nextToken()
+		parser.nextToken()
+		list = append(list, parser.parseExpression(LOWEST))
+	}
+
+	if !parser.panicking {
+		parser.expectPeek(end)
+	}
+
+	return list
+}
+
+// docComment returns the comments directly above currentToken, with no blank
+// line in between, or nil when there are none.
+func docComment(currentToken token.Token) *abstractSyntaxTree.CommentGroup {
+	groups := abstractSyntaxTree.GroupComments(currentToken.LeadingTrivia)
+	if len(groups) == 0 {
+		return nil
+	}
+
+	doc := groups[len(groups)-1]
+	if doc.End().Line < currentToken.Span.Start.Line-1 || doc.List[0].Kind == token.SHEBANG {
+		return nil
+	}
+
+	return doc
+}
@@ -2,10 +2,13 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/diagnostic"
 	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -17,7 +20,7 @@ func TestLetStatements(t *testing.T) {
 
 	lexer := lexer.NewLexer(input)
 	parser := NewParser(lexer)
-	program := parser.parseProgram()
+	program := parser.ParseProgram()
 	checkParserErrors(t, parser)
 
 	if program == nil {
@@ -44,6 +47,41 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetStatementValues(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      int64
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = 10;", "y", 10},
+		{"let foobar = 838383;", "foobar", 838383},
+		{"let x = 5", "x", 5},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		statement := program.Statements[0]
+		if !testLetStatement(t, statement, tt.expectedIdentifier) {
+			return
+		}
+
+		value := statement.(*abstractSyntaxTree.LetStatement).Value
+		if !testIntegerLiteral(t, value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, statement abstractSyntaxTree.Statement, name string) bool {
 	if statement.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", statement.TokenLiteral())
@@ -91,7 +129,7 @@ func TestReturnStatements(t *testing.T) {
 	lexer := lexer.NewLexer(input)
 	parser := NewParser(lexer)
 
-	program := parser.parseProgram()
+	program := parser.ParseProgram()
 
 	checkParserErrors(t, parser)
 
@@ -112,13 +150,75 @@ func TestReturnStatements(t *testing.T) {
 
 }
 
+func TestReturnStatementValues(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue int64
+	}{
+		{"return 5;", 5},
+		{"return 10;", 10},
+		{"return 993322", 993322},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		returnStatement, ok := program.Statements[0].(*abstractSyntaxTree.ReturnStatement)
+		if !ok {
+			t.Fatalf("Statement not *abstractSyntaxTree.ReturnStatement. got=%T", program.Statements[0])
+		}
+		if returnStatement.TokenLiteral() != "return" {
+			t.Errorf("returnStatement.TokenLiteral not 'return', got %q", returnStatement.TokenLiteral())
+		}
+		if !testIntegerLiteral(t, returnStatement.ReturnValue, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestLetAndReturnStatementString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;", "let x = 5;"},
+		{"let x = 5", "let x = 5;"},
+		{"let y = -a * b;", "let y = ((-a) * b);"},
+		{"let z = a + b * c", "let z = (a + (b * c));"},
+		{"return x;", "return x;"},
+		{"return a + b", "return (a + b);"},
+		{"return;", "return ;"},
+		{"return", "return ;"},
+		{"let a = 1 let b = 2", "let a = 1;let b = 2;"},
+		{"let a = 1; return a", "let a = 1;return a;"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar"
 
 	lexer := lexer.NewLexer(input)
 	parser := NewParser(lexer)
 
-	program := parser.parseProgram()
+	program := parser.ParseProgram()
 	checkParserErrors(t, parser)
 
 	if len(program.Statements) != 1 {
@@ -153,7 +253,7 @@ func TestIntegerLiteralExpression(t *testing.T) {
 
 	parser := NewParser(lexer)
 
-	program := parser.parseProgram()
+	program := parser.ParseProgram()
 
 	checkParserErrors(t, parser)
 
@@ -195,7 +295,7 @@ func TestParsingPrefixExpression(t *testing.T) {
 	for _, prefixTest := range prefixTests {
 		lexer := lexer.NewLexer(prefixTest.input)
 		parser := NewParser(lexer)
-		program := parser.parseProgram()
+		program := parser.ParseProgram()
 		checkParserErrors(t, parser)
 
 		if len(program.Statements) != 1 {
@@ -264,6 +364,12 @@ func TestParsingInfixEpression(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 && 5;", 5, "&&", 5},
+		{"5 || 5;", 5, "||", 5},
 	}
 
 	for _, infixTest := range infixTests {
@@ -272,7 +378,7 @@ func TestParsingInfixEpression(t *testing.T) {
 
 		parser := NewParser(lexer)
 
-		program := parser.parseProgram()
+		program := parser.ParseProgram()
 
 		checkParserErrors(t, parser)
 
@@ -350,14 +456,77 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		}, {
-			"3 + 4 * 5 == 3 * 1 + 4 * 5",
-			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
+			"true",
+			"true",
+		}, {
+			"3 > 5 == false",
+			"((3 > 5) == false)",
+		}, {
+			"1 + (2 + 3) + 4",
+			"((1 + (2 + 3)) + 4)",
+		}, {
+			"(5 + 5) * 2",
+			"((5 + 5) * 2)",
+		}, {
+			"-(5 + 5)",
+			"(-(5 + 5))",
+		}, {
+			"!(true == true)",
+			"(!(true == true))",
+		}, {
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		}, {
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		}, {
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		}, {
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		}, {
+			"a || b && c",
+			"(a || (b && c))",
+		}, {
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		}, {
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		}, {
+			"a + b % c",
+			"(a + (b % c))",
+		}, {
+			"a * b ** c",
+			"(a * (b ** c))",
+		}, {
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		}, {
+			"-a ** b",
+			"(-(a ** b))",
+		}, {
+			"a ** -b",
+			"(a ** (-b))",
+		}, {
+			"a ** b(c)",
+			"(a ** b(c))",
+		}, {
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		}, {
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		}, {
+			"f(x)[0]",
+			"(f(x)[0])",
 		},
 	}
 	for _, tt := range tests {
 		l := lexer.NewLexer(tt.input)
 		p := NewParser(l)
-		program := p.parseProgram()
+		program := p.ParseProgram()
 
 		checkParserErrors(t, p)
 		actual := program.String()
@@ -367,3 +536,733 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedBoolean bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+
+		statement, ok := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		boolean, ok := statement.Expression.(*abstractSyntaxTree.Boolean)
+		if !ok {
+			t.Fatalf("exp not *ast.Boolean. got=%T", statement.Expression)
+		}
+		if boolean.Value != tt.expectedBoolean {
+			t.Errorf("boolean.Value not %t. got=%t", tt.expectedBoolean, boolean.Value)
+		}
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	tests := []struct {
+		input               string
+		expected            string
+		expectedAlternative bool
+	}{
+		{"if (x < y) { x }", "if(x < y) x", false},
+		{"if (x < y) { x } else { y }", "if(x < y) xelse y", true},
+		{"if (x) { let a = 1; a }", "ifx let a = 1;a", false},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		statement, ok := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		expression, ok := statement.Expression.(*abstractSyntaxTree.IfExpression)
+		if !ok {
+			t.Fatalf("statement.Expression is not ast.IfExpression. got=%T", statement.Expression)
+		}
+
+		if (expression.Alternative != nil) != tt.expectedAlternative {
+			t.Errorf("expression.Alternative presence wrong. got=%+v", expression.Alternative)
+		}
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := statement.Expression.(*abstractSyntaxTree.FunctionLiteral)
+	if !ok {
+		t.Fatalf("statement.Expression is not ast.FunctionLiteral. got=%T", statement.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement. got=%d", len(function.Body.Statements))
+	}
+
+	if actual := function.String(); actual != "fn(x, y) (x + y)" {
+		t.Errorf("function.String() wrong. got=%q", actual)
+	}
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		statement := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+		function := statement.Expression.(*abstractSyntaxTree.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, identifier := range tt.expectedParams {
+			if function.Parameters[i].Value != identifier {
+				t.Errorf("parameter %d wrong. want %s, got=%s", i, identifier, function.Parameters[i].Value)
+			}
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	expression, ok := statement.Expression.(*abstractSyntaxTree.CallExpression)
+	if !ok {
+		t.Fatalf("statement.Expression is not ast.CallExpression. got=%T", statement.Expression)
+	}
+
+	if expression.Function.String() != "add" {
+		t.Errorf("expression.Function wrong. got=%s", expression.Function.String())
+	}
+
+	if len(expression.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(expression.Arguments))
+	}
+
+	testIntegerLiteral(t, expression.Arguments[0], 1)
+
+	for i, expected := range []string{"1", "(2 * 3)", "(4 + 5)"} {
+		if expression.Arguments[i].String() != expected {
+			t.Errorf("argument %d wrong. want %q, got=%q", i, expected, expression.Arguments[i].String())
+		}
+	}
+}
+
+func TestUnterminatedBlockReportsError(t *testing.T) {
+	lexer := lexer.NewLexer("fn(x) { x")
+	parser := NewParser(lexer)
+	parser.ParseProgram()
+
+	if len(parser.Errors()) == 0 {
+		t.Fatalf("expected an error for unterminated block")
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, 2)"
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	tests := []struct {
+		node          abstractSyntaxTree.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "1:1", "4:10"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*abstractSyntaxTree.LetStatement).Value, "1:11", "3:2"},
+		{program.Statements[1], "4:1", "4:10"},
+	}
+
+	for i, tt := range tests {
+		if actual := tt.node.Pos().String(); actual != tt.expectedStart {
+			t.Errorf("tests[%d] - Pos() wrong. expected=%s, got=%s", i, tt.expectedStart, actual)
+		}
+		if actual := tt.node.End().String(); actual != tt.expectedEnd {
+			t.Errorf("tests[%d] - End() wrong. expected=%s, got=%s", i, tt.expectedEnd, actual)
+		}
+	}
+}
+
+func TestErrorsReportPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+
+	lexer := lexer.NewFileLexer("script.monkey", input)
+	parser := NewParser(lexer)
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	if !strings.HasPrefix(errors[0].Error(), "script.monkey:2:5: ") {
+		t.Errorf("error does not start with position. got=%q", errors[0])
+	}
+}
+
+func TestPeekErrorReportsLookahead(t *testing.T) {
+	lexer := lexer.NewLexer("let x 5;")
+	parser := NewParser(lexer)
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	report := errors[0]
+	if report.Code != diagnostic.UNEXPECTED_TOKEN {
+		t.Errorf("report.Code wrong. got=%s", report.Code)
+	}
+	if report.Message != "expected next token to be =, got INT instead" {
+		t.Errorf("report.Message wrong. got=%q", report.Message)
+	}
+	if report.Span.Start.Column != 7 {
+		t.Errorf("report.Span.Start.Column wrong. got=%d", report.Span.Start.Column)
+	}
+	if len(report.Fixes) != 1 || report.Fixes[0].Replacement != "=" {
+		t.Errorf("report.Fixes wrong. got=%+v", report.Fixes)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedErrors   []string
+		expectedProgram  string
+		expectedBadNodes int
+	}{
+		{
+			"let = 5; let y = 10;",
+			[]string{"1:5: error[E0001]: expected next token to be IDENT, got = instead"},
+			"<bad statement>let y = 10;",
+			1,
+		},
+		{
+			"let x = ; let y = 10;",
+			[]string{"1:9: error[E0002]: expected an expression, got `;`"},
+			"let x = <bad expression>;let y = 10;",
+			1,
+		},
+		{
+			"add(1, ) ; x",
+			[]string{"1:8: error[E0002]: expected an expression, got `)`"},
+			"add(1, <bad expression>)x",
+			1,
+		},
+		{
+			"let f = fn(x) { let y = }; f(1)",
+			[]string{"1:25: error[E0002]: expected an expression, got `}`"},
+			"let f = fn(x) let y = <bad expression>;;f(1)",
+			1,
+		},
+		{
+			"if (x { y } let z = 1",
+			[]string{"1:7: error[E0001]: expected next token to be ), got { instead"},
+			"<bad expression>let z = 1;",
+			1,
+		},
+		{
+			"let a = 1 +; let b = ); let c = 3",
+			[]string{
+				"1:12: error[E0002]: expected an expression, got `;`",
+				"1:22: error[E0002]: expected an expression, got `)`",
+			},
+			"let a = (1 + <bad expression>);let b = <bad expression>;let c = 3;",
+			2,
+		},
+		{
+			"} let x = 1",
+			[]string{"1:1: error[E0002]: expected an expression, got `}`"},
+			"<bad expression>let x = 1;",
+			1,
+		},
+		{
+			"puts(1.)",
+			[]string{"1:7: error[E0011]: unexpected character '.'"},
+			"puts(1)",
+			0,
+		},
+		{
+			"let @ = 5; let y = 1",
+			[]string{"1:5: error[E0011]: unexpected character '@'"},
+			"<bad statement>let y = 1;",
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("input %q: expected %d errors, got %d: %v", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+
+		for i, expected := range tt.expectedErrors {
+			if errors[i].Error() != expected {
+				t.Errorf("input %q: errors[%d] wrong. expected=%q, got=%q", tt.input, i, expected, errors[i].Error())
+			}
+		}
+
+		if actual := program.String(); actual != tt.expectedProgram {
+			t.Errorf("input %q: program wrong. expected=%q, got=%q", tt.input, tt.expectedProgram, actual)
+		}
+
+		if actual := strings.Count(program.String(), "<bad"); actual != tt.expectedBadNodes {
+			t.Errorf("input %q: expected %d bad nodes, got %d", tt.input, tt.expectedBadNodes, actual)
+		}
+	}
+}
+
+func TestErrorLimit(t *testing.T) {
+	input := strings.Repeat("let = 1;\n", MAX_ERRORS+5)
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) != MAX_ERRORS+1 {
+		t.Fatalf("expected %d errors, got %d", MAX_ERRORS+1, len(errors))
+	}
+
+	if errors[MAX_ERRORS].Code != diagnostic.TOO_MANY_ERRORS {
+		t.Errorf("last error is not TOO_MANY_ERRORS. got=%s", errors[MAX_ERRORS].Code)
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld";`
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	literal, ok := statement.Expression.(*abstractSyntaxTree.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", statement.Expression)
+	}
+
+	if literal.Value != "hello\tworld" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld", literal.Value)
+	}
+
+	if literal.String() != `"hello\tworld"` {
+		t.Errorf("literal.String() wrong. got=%q", literal.String())
+	}
+}
+
+func TestLexerErrorsAreReported(t *testing.T) {
+	lexer := lexer.NewLexer(`let s = "abc`)
+	parser := NewParser(lexer)
+	parser.ParseProgram()
+
+	errors := parser.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errors), errors)
+	}
+
+	if errors[0].Code != diagnostic.UNTERMINATED_STRING {
+		t.Errorf("error code wrong. got=%s", errors[0].Code)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `// Package level note.
+
+// add returns the sum
+// of two numbers.
+let add = fn(x, y) { x + y }; // not a doc
+
+let noDoc = 1;
+
+/* twice doubles x. */
+let twice = fn(x) { x * 2 };`
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	tests := []struct {
+		expectedDoc string
+	}{
+		{"add returns the sum\nof two numbers."},
+		{""},
+		{"twice doubles x."},
+	}
+
+	for i, tt := range tests {
+		letStatement := program.Statements[i].(*abstractSyntaxTree.LetStatement)
+
+		actual := ""
+		if letStatement.Doc != nil {
+			actual = letStatement.Doc.Text()
+		}
+
+		if actual != tt.expectedDoc {
+			t.Errorf("statement %d doc wrong. expected=%q, got=%q", i, tt.expectedDoc, actual)
+		}
+	}
+
+	if len(program.Comments) != 4 {
+		t.Fatalf("program.Comments has %d groups, want 4", len(program.Comments))
+	}
+
+	if program.Comments[2].String() != "// not a doc" {
+		t.Errorf("program.Comments[2] wrong. got=%q", program.Comments[2].String())
+	}
+}
+
+func TestNumberLiteralExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedInt   int64
+		expectedFloat float64
+		isFloat       bool
+	}{
+		{"0xff", 255, 0, false},
+		{"0o17", 15, 0, false},
+		{"0b1010", 10, 0, false},
+		{"1_000_000", 1000000, 0, false},
+		{"0", 0, 0, false},
+		{"0.5", 0, 0.5, true},
+		{"3.14", 0, 3.14, true},
+		{"1e-9", 0, 1e-9, true},
+		{"2_500.5", 0, 2500.5, true},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		expression := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement).Expression
+
+		if tt.isFloat {
+			literal, ok := expression.(*abstractSyntaxTree.FloatLiteral)
+			if !ok {
+				t.Errorf("input %s: exp not *ast.FloatLiteral. got=%T", tt.input, expression)
+				continue
+			}
+			if literal.Value != tt.expectedFloat {
+				t.Errorf("input %s: literal.Value not %g. got=%g", tt.input, tt.expectedFloat, literal.Value)
+			}
+			continue
+		}
+
+		literal, ok := expression.(*abstractSyntaxTree.IntegerLiteral)
+		if !ok {
+			t.Errorf("input %s: exp not *ast.IntegerLiteral. got=%T", tt.input, expression)
+			continue
+		}
+		if literal.Value != tt.expectedInt {
+			t.Errorf("input %s: literal.Value not %d. got=%d", tt.input, tt.expectedInt, literal.Value)
+		}
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x = 99999999999999999999;", "1:9: error[E0010]: integer literal 99999999999999999999 does not fit in 64 bits"},
+		{"let x = 0x1_0000_0000_0000_0000;", "1:9: error[E0010]: integer literal 0x1_0000_0000_0000_0000 does not fit in 64 bits"},
+		{"let x = 1e400;", "1:9: error[E0010]: float literal 1e400 is out of range"},
+		{"let x = 0b12;", "1:9: error[E0003]: invalid number literal 0b12: digit '2' is not valid in base 2"},
+		{"let x = 1 + @;", "1:13: error[E0011]: unexpected character '@'"},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		parser.ParseProgram()
+
+		errors := parser.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %s: expected 1 error, got %d: %v", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0].Error() != tt.expectedError {
+			t.Errorf("input %s: error wrong. expected=%q, got=%q", tt.input, tt.expectedError, errors[0].Error())
+		}
+
+		if errors[0].Span.End.Column != len(tt.input) {
+			t.Errorf("input %s: error span does not cover the literal. got=%+v", tt.input, errors[0].Span)
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	array, ok := statement.Expression.(*abstractSyntaxTree.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", statement.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+
+	if array.String() != "[1, (2 * 2), (3 + 3)]" {
+		t.Errorf("array.String() wrong. got=%q", array.String())
+	}
+
+	if array.End().Column != 18 {
+		t.Errorf("array.End() wrong. got=%s", array.End())
+	}
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	lexer := lexer.NewLexer("[]")
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	array, ok := statement.Expression.(*abstractSyntaxTree.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", statement.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	indexExpression, ok := statement.Expression.(*abstractSyntaxTree.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", statement.Expression)
+	}
+
+	if indexExpression.Left.String() != "myArray" {
+		t.Errorf("indexExpression.Left wrong. got=%s", indexExpression.Left.String())
+	}
+
+	if indexExpression.Index.String() != "(1 + 1)" {
+		t.Errorf("indexExpression.Index wrong. got=%s", indexExpression.Index.String())
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	statement := program.Statements[0].(*abstractSyntaxTree.ExpressionStatement)
+	hash, ok := statement.Expression.(*abstractSyntaxTree.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", statement.Expression)
+	}
+
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*abstractSyntaxTree.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("key %d wrong. want=%q, got=%q", i, expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, `{}`},
+		{`{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`, `{"one": (0 + 1), "two": (10 - 8), "three": (15 / 5)}`},
+		{`{1: true, false: "no",}`, `{1: true, false: "no"}`},
+		{`{"a": {"b": 1}}["a"]["b"]`, `(({"a": {"b": 1}}["a"])["b"])`},
+		{`if (x) { {"a": 1} }`, `ifx {"a": 1}`},
+		{`fn() { {} }`, `fn() {}`},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("input %s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestHashLiteralErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		{
+			`let h = {"a" 1}; let x = 2;`,
+			`let h = {"a": };let x = 2;`,
+			[]string{"1:14: expected next token to be :, got INT instead"},
+		},
+		{
+			`let f = fn() { let h = {"a": , "b": 2}; h }; f();`,
+			`let f = fn() let h = {"a": <bad expression>};h;f()`,
+			[]string{"1:30: expected an expression, got `,`"},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := lexer.NewLexer(tt.input)
+		parser := NewParser(lexer)
+		program := parser.ParseProgram()
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("input %s: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+
+		errors := parser.Errors()
+		if len(errors) != len(tt.errors) {
+			t.Errorf("input %s: expected %d errors, got %d: %v", tt.input, len(tt.errors), len(errors), errors)
+			continue
+		}
+		for i, message := range tt.errors {
+			if actual := fmt.Sprintf("%s: %s", errors[i].Span, errors[i].Message); actual != message {
+				t.Errorf("input %s: error %d wrong. expected=%q, got=%q", tt.input, i, message, actual)
+			}
+		}
+	}
+}
+
+func TestShebangIsNotADocComment(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1;"
+
+	lexer := lexer.NewLexer(input)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	letStatement := program.Statements[0].(*abstractSyntaxTree.LetStatement)
+	if letStatement.Doc != nil {
+		t.Errorf("letStatement.Doc should be nil. got=%q", letStatement.Doc.String())
+	}
+
+	if len(program.Comments) != 1 || program.Comments[0].List[0].Kind != token.SHEBANG {
+		t.Errorf("program.Comments wrong. got=%v", program.Comments)
+	}
+}
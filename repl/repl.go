@@ -4,15 +4,34 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/Favot/monkey-interpreter/diagnostic"
+	"github.com/Favot/monkey-interpreter/evaluator"
 	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/object"
+	"github.com/Favot/monkey-interpreter/parser"
 	"github.com/Favot/monkey-interpreter/token"
 )
 
 const PROMPT = "Monkey >> "
 
+const (
+	TOKENS_COMMAND = ":tokens"
+	EVAL_COMMAND   = ":eval"
+)
+
+type mode int
+
+const (
+	evalMode mode = iota
+	tokensMode
+)
+
 func StartRepl(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	environment := object.NewEnvironment()
+	currentMode := evalMode
 
 	for {
 		fmt.Fprint(out, PROMPT)
@@ -22,10 +41,46 @@ func StartRepl(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		lexer := lexer.NewLexer(line)
 
-		for currentToken := lexer.NextToken(); currentToken.Type != token.EOF; currentToken = lexer.NextToken() {
-			fmt.Printf("%+v\n", currentToken)
+		switch strings.TrimSpace(line) {
+		case TOKENS_COMMAND:
+			currentMode = tokensMode
+			fmt.Fprintln(out, "token mode: input is lexed and every token is printed")
+			continue
+		case EVAL_COMMAND:
+			currentMode = evalMode
+			fmt.Fprintln(out, "eval mode: input is parsed and evaluated")
+			continue
+		}
+
+		if currentMode == tokensMode {
+			printTokens(out, line)
+			continue
+		}
+
+		parser := parser.NewParser(lexer.NewLexer(line))
+		program := parser.ParseProgram()
+		if len(parser.Errors()) != 0 {
+			printParserErrors(out, line, parser.Errors())
+			continue
 		}
+
+		evaluated := evaluator.Eval(program, environment)
+		if evaluated != nil {
+			fmt.Fprintln(out, evaluated.Inspect())
+		}
+	}
+}
+
+func printTokens(out io.Writer, line string) {
+	lexer := lexer.NewLexer(line)
+
+	for currentToken := lexer.NextToken(); currentToken.Type != token.EOF; currentToken = lexer.NextToken() {
+		fmt.Fprintf(out, "%s\t%s\t%q\n", currentToken.Span, currentToken.Type, currentToken.Literal)
 	}
 }
+
+func printParserErrors(out io.Writer, line string, errors diagnostic.ErrorList) {
+	errors.Sort()
+	diagnostic.RenderAll(out, line, errors)
+}
@@ -0,0 +1,39 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStartRepl(t *testing.T) {
+	input := strings.Join([]string{
+		"1 + 2 * 3",
+		":tokens",
+		"5;",
+		":eval",
+		"5 <",
+		"foobar",
+		"let x = 21",
+		"x * 2",
+	}, "\n")
+
+	var out bytes.Buffer
+	StartRepl(strings.NewReader(input), &out)
+
+	expected := []string{
+		"7",
+		"1:1\tINT\t\"5\"",
+		"1:2\t;\t\";\"",
+		"1:4: error[E0002]: expected an expression, got end of input",
+		"1 | 5 <\n  |    ^\n",
+		"identifier not found: foobar",
+		"42",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("repl output does not contain %q. got=%q", want, out.String())
+		}
+	}
+}
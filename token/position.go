@@ -0,0 +1,38 @@
+package token
+
+import "fmt"
+
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (position Position) IsValid() bool { return position.Line > 0 }
+
+func (position Position) String() string {
+	location := position.Filename
+
+	if position.IsValid() {
+		if location != "" {
+			location += ":"
+		}
+		location += fmt.Sprintf("%d:%d", position.Line, position.Column)
+	}
+
+	if location == "" {
+		location = "-"
+	}
+
+	return location
+}
+
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (span Span) String() string {
+	return span.Start.String()
+}
@@ -5,6 +5,13 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
+
+	// LeadingTrivia holds the comments between the previous token's
+	// trailing trivia and this token. TrailingTrivia holds the comments that
+	// follow this token on the same line.
+	LeadingTrivia  []Trivia
+	TrailingTrivia []Trivia
 }
 
 const (
@@ -12,8 +19,10 @@ const (
 	EOF     = "EOF"
 
 	// Identifiers + literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
 
 	// Operators
 	ASSIGN   = "="
@@ -22,21 +31,31 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 
-	LESS_THAN    = "<"
-	GREATER_THAN = ">"
+	LESS_THAN     = "<"
+	GREATER_THAN  = ">"
+	LESS_EQUAL    = "<="
+	GREATER_EQUAL = ">="
 
 	EQUALS     = "=="
 	NOT_EQUALS = "!="
 
+	AND = "&&"
+	OR  = "||"
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
 	LEFT_PARENTHESIS  = "("
 	RIGHT_PARENTHESIS = ")"
 	LEFT_BRACE        = "{"
 	RIGHT_BRACE       = "}"
+	LEFT_BRACKET      = "["
+	RIGHT_BRACKET     = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
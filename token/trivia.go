@@ -0,0 +1,20 @@
+package token
+
+type TriviaKind int
+
+const (
+	LINE_COMMENT TriviaKind = iota
+	BLOCK_COMMENT
+	// SHEBANG is a `#!` line at the very start of a script, which the lexer
+	// keeps so that the script can be made executable.
+	SHEBANG
+)
+
+// Trivia is source text that carries no meaning for the parser but that
+// tools such as a formatter need to reproduce. Text includes the comment
+// markers.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Span Span
+}
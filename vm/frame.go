@@ -0,0 +1,22 @@
+package vm
+
+import (
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+// Frame is one closure call in progress. basePointer is the stack slot
+// where the function's locals start.
+type Frame struct {
+	closure     *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(closure *object.Closure, basePointer int) *Frame {
+	return &Frame{closure: closure, ip: -1, basePointer: basePointer}
+}
+
+func (frame *Frame) Instructions() code.Instructions {
+	return frame.closure.Function.Instructions
+}
@@ -0,0 +1,525 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Favot/monkey-interpreter/code"
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/object"
+)
+
+const (
+	STACK_SIZE   = 1 << 16
+	GLOBALS_SIZE = 1 << 16
+	MAX_FRAMES   = evaluator.MAX_CALL_DEPTH
+)
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	// sp always points to the next free slot; the top of the stack is
+	// stack[sp-1].
+	sp int
+
+	frames      []*Frame
+	framesIndex int
+
+	ctx context.Context
+
+	// Trace, when set, receives a line for every instruction executed: the
+	// function, offset, source line and instruction, followed by the stack
+	// as it was before the instruction ran.
+	Trace io.Writer
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GLOBALS_SIZE))
+}
+
+// NewWithGlobalsStore creates a VM that reads and writes globals in globals,
+// so that they survive from one program to the next.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFunction := &object.CompiledFunction{Instructions: bytecode.Instructions, Lines: bytecode.Lines}
+	mainClosure := &object.Closure{Function: mainFunction}
+
+	frames := make([]*Frame, MAX_FRAMES)
+	frames[0] = NewFrame(mainClosure, 0)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, STACK_SIZE),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		ctx: context.Background(),
+	}
+}
+
+// LastPoppedStackElement returns the value of the last expression statement
+// the program ran, or nil if it ended with a let statement.
+func (vm *VM) LastPoppedStackElement() object.Object {
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext runs the program until it ends, fails or ctx is cancelled.
+func (vm *VM) RunContext(ctx context.Context) error {
+	vm.ctx = ctx
+	return vm.execute(0)
+}
+
+// Call calls function with arguments on top of whatever the VM has run so
+// far, so that Go code can call a function a program defined.
+func (vm *VM) Call(function object.Object, arguments []object.Object) (object.Object, error) {
+	depth := vm.framesIndex
+
+	if err := vm.push(function); err != nil {
+		return nil, err
+	}
+	for _, argument := range arguments {
+		if err := vm.push(argument); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := vm.callFunction(len(arguments)); err != nil {
+		return nil, err
+	}
+	if err := vm.execute(depth); err != nil {
+		return nil, err
+	}
+
+	return vm.pop(), nil
+}
+
+// execute runs instructions until the frame at depth+1 is left or, for the
+// main program, until its instructions run out.
+func (vm *VM) execute(depth int) error {
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip := vm.currentFrame().ip
+		instructions := vm.currentFrame().Instructions()
+		op := code.Opcode(instructions[ip])
+
+		if vm.Trace != nil {
+			vm.traceInstruction(ip, instructions)
+		}
+
+		switch op {
+		case code.OpConstant:
+			constantIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constantIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+
+			if err := vm.pushResult(evaluator.EvalInfix(operators[op], left, right)); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			if err := vm.pushResult(evaluator.EvalPrefix("-", vm.pop())); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.pushResult(evaluator.EvalPrefix("!", vm.pop())); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(evaluator.TRUE); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(evaluator.FALSE); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(evaluator.NULL); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			position := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip = position - 1
+
+		case code.OpJumpNotTruthy, code.OpJumpTruthy:
+			position := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if evaluator.IsTruthy(condition) == (op == code.OpJumpTruthy) {
+				vm.currentFrame().ip = position - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+			// A let statement has no value, so it must not show up as the
+			// last popped element.
+			vm.stack[vm.sp] = nil
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(instructions[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return fmt.Errorf("identifier not found: %s", vm.globalName(int(globalIndex)))
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = vm.pop()
+			}
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(instructions[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.pushResult(evaluator.EvalIndex(left, index)); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArguments := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.callFunction(int(numArguments)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if err := vm.returnFromFrame(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			if err := vm.returnFromFrame(evaluator.NULL); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constantIndex := code.ReadUint16(instructions[ip+1:])
+			numFree := code.ReadUint8(instructions[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constantIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(vm.currentFrame().closure.Free[freeIndex].Value); err != nil {
+				return err
+			}
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			// A local moves into a cell the first time a closure captures
+			// it, and stays there for the rest of the call.
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: *slot}
+				*slot = cell
+			}
+			if err := vm.push(cell); err != nil {
+				return err
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(instructions[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(vm.currentFrame().closure.Free[freeIndex]); err != nil {
+				return err
+			}
+
+		default:
+			definition, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("opcode %s not implemented", definition.Name)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) traceInstruction(ip int, instructions code.Instructions) {
+	function := vm.currentFrame().closure.Function
+
+	name := "main"
+	if vm.framesIndex > 1 {
+		name = compiler.Describe(function)
+	}
+
+	instruction := "?"
+	if definition, err := code.Lookup(instructions[ip]); err == nil {
+		operands, _ := code.ReadOperands(definition, instructions[ip+1:])
+		instruction = code.FormatOperands(definition, operands)
+	}
+
+	stack := make([]string, vm.sp)
+	for index, obj := range vm.stack[:vm.sp] {
+		stack[index] = compiler.Describe(obj)
+	}
+
+	fmt.Fprintf(vm.Trace, "%-12s %04d %4d  %-28s [%s]\n",
+		name, ip, function.Lines.Line(ip), instruction, strings.Join(stack, ", "))
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(frame *Frame) error {
+	if vm.framesIndex >= MAX_FRAMES {
+		return fmt.Errorf("stack overflow: more than %d nested calls", MAX_FRAMES)
+	}
+
+	vm.frames[vm.framesIndex] = frame
+	vm.framesIndex++
+
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) callFunction(numArguments int) error {
+	callee := vm.stack[vm.sp-1-numArguments]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		function := callee.Function
+		if numArguments != function.NumParameters {
+			return fmt.Errorf("wrong number of arguments: want=%d, got=%d", function.NumParameters, numArguments)
+		}
+		// Every loop in Monkey is a recursive call, so checking here is enough
+		// to stop a cancelled program.
+		if err := vm.ctx.Err(); err != nil {
+			return err
+		}
+
+		frame := NewFrame(callee, vm.sp-numArguments)
+		if err := vm.pushFrame(frame); err != nil {
+			return err
+		}
+
+		vm.sp = frame.basePointer + function.NumLocals
+		if vm.sp >= STACK_SIZE {
+			return errors.New("stack overflow")
+		}
+
+		// Clear what an earlier call left in the slots of the other locals,
+		// which could be a cell that a closure still holds.
+		clear(vm.stack[frame.basePointer+numArguments : vm.sp])
+
+		return nil
+
+	case *object.Builtin:
+		arguments := vm.stack[vm.sp-numArguments : vm.sp]
+
+		result := callee.Function(arguments...)
+		vm.sp = vm.sp - numArguments - 1
+
+		if result == nil {
+			result = evaluator.NULL
+		}
+		return vm.pushResult(result)
+
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+// returnFromFrame leaves the current function with returnValue. A return in
+// the main program ends it, with returnValue as its result.
+func (vm *VM) returnFromFrame(returnValue object.Object) error {
+	if vm.framesIndex == 1 {
+		vm.stack[vm.sp] = returnValue
+		vm.currentFrame().ip = len(vm.currentFrame().Instructions()) - 1
+		return nil
+	}
+
+	frame := vm.popFrame()
+	vm.sp = frame.basePointer - 1
+
+	return vm.push(returnValue)
+}
+
+// pushClosure wraps the function at constantIndex in a closure that
+// captures the numFree cells on top of the stack.
+func (vm *VM) pushClosure(constantIndex, numFree int) error {
+	function, ok := vm.constants[constantIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %s", vm.constants[constantIndex].Type())
+	}
+
+	free := make([]*object.Cell, numFree)
+	for index, captured := range vm.stack[vm.sp-numFree : vm.sp] {
+		cell, ok := captured.(*object.Cell)
+		if !ok {
+			return fmt.Errorf("cannot capture %s", captured.Type())
+		}
+		free[index] = cell
+	}
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Function: function, Free: free})
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) globalName(index int) string {
+	if index < len(vm.globalNames) {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+// pushResult pushes the result of an operation, turning an error object into
+// a Go error that stops the VM.
+func (vm *VM) pushResult(result object.Object) error {
+	if errorObject, ok := result.(*object.Error); ok {
+		return errors.New(errorObject.Message)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) push(obj object.Object) error {
+	if vm.sp >= STACK_SIZE {
+		return errors.New("stack overflow")
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
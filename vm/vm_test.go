@@ -0,0 +1,431 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Favot/monkey-interpreter/abstractSyntaxTree"
+	"github.com/Favot/monkey-interpreter/compiler"
+	"github.com/Favot/monkey-interpreter/evaluator"
+	"github.com/Favot/monkey-interpreter/lexer"
+	"github.com/Favot/monkey-interpreter/object"
+	"github.com/Favot/monkey-interpreter/parser"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"4 / 2 * 3", 6},
+		{"7 % 3", 1},
+		{"2 ** 3 ** 2", 512},
+		{"-5 + 10", 5},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5 + 1.5", 3.0},
+		{"1 / 2.0", 0.5},
+		{"-2.5", -2.5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"2 <= 2", true},
+		{"1 >= 2", false},
+		{"1 == 1", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+		{"true && false", false},
+		{"1 && 2", true},
+		{"false || 0", true},
+		{"false || false", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key"`, "monkey"},
+		{`"a" == "a"`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", nil},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { let a = 5; }", nil},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let f = fn() { g }; let g = 5; f()", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1 + 2, 3 * 4]", []int{3, 12}},
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[1, 2, 3][3]", nil},
+		{"{1: 2, 2: 3}[1]", 2},
+		{`{"a": 5}["b"]`, nil},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { 5 + 10; }; f();", 15},
+		{"let f = fn() { return 99; 100; }; f();", 99},
+		{"let f = fn() { }; f();", nil},
+		{"let one = fn() { 1 }; let two = fn() { one() + 1 }; two();", 2},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2) + sum(3, 4);", 10},
+		{"let global = 10; let f = fn(a) { let local = a * 2; global + local }; f(1) + f(2)", 26},
+		{"let returnsOne = fn() { 1 }; let caller = fn() { returnsOne }; caller()()", 1},
+		{"let count = fn(n) { if (n == 0) { return 0; } count(n - 1) }; count(1000)", 0},
+		{"fn(a) { a * 2 }(21)", 42},
+		{"return 1; 2", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();", 99},
+		{"let newAdder = fn(a, b) { fn(c) { a + b + c }; }; let adder = newAdder(1, 2); adder(8);", 11},
+		{"let newAdder = fn(a, b) { let c = a + b; fn(d) { c + d }; }; let adder = newAdder(1, 2); adder(8);", 11},
+		{
+			`let newAdderOuter = fn(a, b) {
+				let c = a + b;
+				fn(d) {
+					let e = d + c;
+					fn(f) { e + f; };
+				};
+			};
+			let newAdderInner = newAdderOuter(1, 2);
+			let adder = newAdderInner(3);
+			adder(8);`,
+			14,
+		},
+		{
+			`let a = 1;
+			let newAdderOuter = fn(b) { fn(c) { fn(d) { a + b + c + d }; }; };
+			newAdderOuter(2)(3)(8);`,
+			14,
+		},
+		{
+			`let newClosure = fn(a, b) {
+				let one = fn() { a; };
+				let two = fn() { b; };
+				fn() { one() + two(); };
+			};
+			newClosure(9, 90)();`,
+			99,
+		},
+		{
+			`let counter = fn(count) {
+				fn() { [count + 1, counter(count + 1)] }
+			};
+			let step = counter(0)()[1];
+			step()[0];`,
+			2,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1); };
+			let wrapper = fn() { countDown(1); };
+			wrapper();`,
+			0,
+		},
+		{
+			`let wrapper = fn() {
+				let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1); };
+				countDown(1);
+			};
+			wrapper();`,
+			0,
+		},
+		{
+			`let wrapper = fn() {
+				let fibonacci = fn(x) {
+					if (x < 2) { return x; }
+					fibonacci(x - 1) + fibonacci(x - 2)
+				};
+				let run = fn() { fibonacci(15) };
+				run();
+			};
+			wrapper();`,
+			610,
+		},
+		{
+			`let sum = fn(limit) {
+				let loop = fn(i, total) { if (i > limit) { return total; } loop(i + 1, total + i) };
+				loop(1, 0)
+			};
+			sum(100);`,
+			5050,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"missing", "identifier not found: missing"},
+		{"let f = fn() { g }; f(); let g = 1;", "identifier not found: g"},
+		{"fn(a) { a }()", "wrong number of arguments: want=1, got=0"},
+		{"1()", "not a function: INTEGER"},
+		{"{fn() {}: 1}", "unusable as hash key: FUNCTION"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { f() }; f()", "stack overflow: more than 16384 nested calls"},
+	}
+
+	for _, tt := range tests {
+		vm := New(compile(t, tt.input))
+
+		err := vm.Run()
+		if err == nil {
+			t.Errorf("input %s: expected VM error but got none", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("input %s: wrong error. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	vm := New(compile(t, "let f = fn() { f() }; f()"))
+
+	if err := vm.RunContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	vm := New(compile(t, "let add = fn(a, b) { a + b }; add"))
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	add := vm.LastPoppedStackElement()
+
+	result, err := vm.Call(add, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "add(1, 2)", 3, result)
+
+	if _, err := vm.Call(add, nil); err == nil {
+		t.Errorf("expected an arity error")
+	}
+}
+
+// TestEnginesAgree runs the same programs through the evaluator and the VM.
+func TestEnginesAgree(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15)",
+		`let map = fn(arr, f) { if (len(arr) == 0) { return []; } push(map(rest(arr), f), f(first(arr))) }; map([1, 2, 3], fn(x) { x * x })`,
+		`let h = {"one": 1, true: 2, 3: "three"}; [h["one"], h[true], h[3], h[4]]`,
+		"let x = 10; if (x > 5 && x < 20 || false) { x * 2 } else { x }",
+		"[1.5 * 2, 7 % 4, 2 ** 10, -3 / 2]",
+		"let make = fn(step) { let next = fn(n) { if (n > 20) { return []; } push(next(n + step), n) }; next }; make(5)(1)",
+	}
+
+	for _, input := range inputs {
+		program := parse(input)
+		expected := evaluator.Eval(program, object.NewEnvironment())
+
+		vm := New(compile(t, input))
+		if err := vm.Run(); err != nil {
+			t.Fatalf("input %s: vm error: %s", input, err)
+		}
+
+		if actual := vm.LastPoppedStackElement(); actual.Inspect() != expected.Inspect() {
+			t.Errorf("input %s: engines disagree. eval=%s, vm=%s", input, expected.Inspect(), actual.Inspect())
+		}
+	}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		vm := New(compile(t, tt.input))
+		if err := vm.Run(); err != nil {
+			t.Fatalf("input %s: vm error: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, vm.LastPoppedStackElement())
+	}
+}
+
+func parse(input string) *abstractSyntaxTree.Program {
+	lexer := lexer.NewLexer(input)
+	parser := parser.NewParser(lexer)
+	return parser.ParseProgram()
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	compiler := compiler.New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("input %s: compiler error: %s", input, err)
+	}
+
+	return compiler.Bytecode()
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("input %s: want=%d, got=%s (%T)", input, expected, inspect(actual), actual)
+		}
+
+	case float64:
+		float, ok := actual.(*object.Float)
+		if !ok || float.Value != expected {
+			t.Errorf("input %s: want=%g, got=%s (%T)", input, expected, inspect(actual), actual)
+		}
+
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("input %s: want=%t, got=%s (%T)", input, expected, inspect(actual), actual)
+		}
+
+	case string:
+		str, ok := actual.(*object.String)
+		if !ok || str.Value != expected {
+			t.Errorf("input %s: want=%q, got=%s (%T)", input, expected, inspect(actual), actual)
+		}
+
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("input %s: want=%v, got=%s (%T)", input, expected, inspect(actual), actual)
+			return
+		}
+		for i, element := range expected {
+			testExpectedObject(t, input, element, array.Elements[i])
+		}
+
+	case nil:
+		if actual != evaluator.NULL {
+			t.Errorf("input %s: want=NULL, got=%s (%T)", input, inspect(actual), actual)
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+func TestTrace(t *testing.T) {
+	var out strings.Builder
+
+	vm := New(compile(t, "let double = fn(x) { x * 2 };\ndouble(\"a\" == \"a\")"))
+	vm.Trace = &out
+
+	if err := vm.Run(); err == nil || err.Error() != "type mismatch: BOOLEAN * INTEGER" {
+		t.Fatalf("expected a type error, got=%v", err)
+	}
+
+	expected := `main         0000    1  OpClosure constant=1 free=0  []
+main         0004    1  OpSetGlobal global=0         [closure double]
+main         0007    2  OpGetGlobal global=0         []
+main         0010    2  OpConstant constant=2        [closure double]
+main         0013    2  OpConstant constant=3        [closure double, "a"]
+main         0016    2  OpEqual                      [closure double, "a", "a"]
+main         0017    2  OpCall arguments=1           [closure double, true]
+fn double    0000    1  OpGetLocal local=0           [closure double, true]
+fn double    0002    1  OpConstant constant=0        [closure double, true, true]
+fn double    0005    1  OpMul                        [closure double, true, true, 2]
+`
+
+	if out.String() != expected {
+		t.Errorf("trace wrong.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}